@@ -0,0 +1,115 @@
+package progress
+
+import "sync"
+
+// eventBus decouples the tasks from the consumer of the events. Publishing
+// never blocks, events are queued until the consumer is ready. While queued,
+// progress updates and logs of a task are coalesced into a single event.
+// Lifecycle events are never dropped or reordered.
+type eventBus struct {
+	mu      sync.Mutex
+	queue   []*TaskEvent
+	pending map[uint64]*TaskEvent // queued events that can be merged, by task ID
+	notify  chan struct{}
+	closed  bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		pending: make(map[uint64]*TaskEvent),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// publish queues te. Events published after close are discarded.
+func (b *eventBus) publish(te *TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	// later updates must not be merged into events queued before te
+	delete(b.pending, te.ID)
+
+	b.queue = append(b.queue, te)
+	b.wake()
+}
+
+// update queues a progress update, item label and logs for the task with the
+// given ID. If current is 0 or label is empty, they are left as is. If an
+// update for the task is still queued, the update is merged into it. The
+// higher progress wins the merge, as concurrent updates of a counter may
+// arrive out of order.
+func (b *eventBus) update(id, current uint64, label string, logs []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	if te, ok := b.pending[id]; ok {
+		if current > te.Current {
+			te.Current = current
+		}
+		if label != "" {
+			te.Label = label
+		}
+		te.Logs = append(te.Logs, logs...)
+		return
+	}
+
+	te := &TaskEvent{
+		ID:      id,
+		Current: current,
+		Label:   label,
+		Logs:    append([]byte(nil), logs...),
+	}
+	b.pending[id] = te
+	b.queue = append(b.queue, te)
+	b.wake()
+}
+
+// close marks the end of the event stream. Queued events are still delivered.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.wake()
+}
+
+func (b *eventBus) wake() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// forward sends all published events to ch and closes ch after the bus has
+// been closed and drained.
+func (b *eventBus) forward(ch chan<- *TaskEvent) {
+	for {
+		b.mu.Lock()
+		events, closed := b.queue, b.closed
+		b.queue = nil
+		if len(b.pending) > 0 {
+			b.pending = make(map[uint64]*TaskEvent)
+		}
+		b.mu.Unlock()
+
+		for _, te := range events {
+			ch <- te
+		}
+
+		if len(events) == 0 {
+			if closed {
+				close(ch)
+				return
+			}
+			<-b.notify
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package progress
+
+import "testing"
+
+// drain closes b and returns all events forwarded by it.
+func drain(b *eventBus) []*TaskEvent {
+	b.close()
+	ch := make(chan *TaskEvent)
+	go b.forward(ch)
+
+	var events []*TaskEvent
+	for te := range ch {
+		events = append(events, te)
+	}
+	return events
+}
+
+func TestBusUpdateKeepsHighestProgress(t *testing.T) {
+	b := newEventBus()
+	b.update(1, 5, "", nil)
+	b.update(1, 4, "", nil)
+	b.update(1, 0, "", nil)
+
+	events := drain(b)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].Current != 5 {
+		t.Fatalf("got current %d, want 5", events[0].Current)
+	}
+}
+
+func TestBusCoalescesUpdates(t *testing.T) {
+	b := newEventBus()
+	b.publish(&TaskEvent{ID: 1, Name: "a"})
+	b.update(1, 1, "first", []byte("x\n"))
+	b.update(2, 7, "", nil)
+	b.update(1, 2, "", []byte("y\n"))
+
+	events := drain(b)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	if events[0].Name != "a" {
+		t.Errorf("got %+v first, want the published event", events[0])
+	}
+	if te := events[1]; te.ID != 1 || te.Current != 2 || te.Label != "first" || string(te.Logs) != "x\ny\n" {
+		t.Errorf("got merged update %+v", te)
+	}
+	if te := events[2]; te.ID != 2 || te.Current != 7 {
+		t.Errorf("got update %+v, want the update of task 2", te)
+	}
+}
+
+func TestBusKeepsOrderAroundPublish(t *testing.T) {
+	b := newEventBus()
+	b.update(1, 1, "", []byte("before\n"))
+	b.publish(&TaskEvent{ID: 1, IsDone: true})
+	b.update(1, 0, "", []byte("after\n"))
+
+	events := drain(b)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	if string(events[0].Logs) != "before\n" || !events[1].IsDone || string(events[2].Logs) != "after\n" {
+		t.Fatalf("events reordered: %+v %+v %+v", events[0], events[1], events[2])
+	}
+}
+
+func TestBusForward(t *testing.T) {
+	b := newEventBus()
+	ch := make(chan *TaskEvent)
+	go b.forward(ch)
+
+	b.publish(&TaskEvent{ID: 1})
+	if te := <-ch; te.ID != 1 {
+		t.Fatalf("got event for task %d, want 1", te.ID)
+	}
+
+	// the update is queued while the consumer is busy and merged
+	b.update(1, 1, "", []byte("a"))
+	b.update(1, 2, "", []byte("b"))
+	te := <-ch
+	if te.Current != 2 {
+		t.Fatalf("got current %d, want 2", te.Current)
+	}
+	logs := string(te.Logs)
+	if logs == "a" {
+		// forwarded before the second update was queued
+		te = <-ch
+		logs += string(te.Logs)
+	}
+	if logs != "ab" {
+		t.Fatalf("got logs %q, want %q", logs, "ab")
+	}
+
+	b.close()
+	b.publish(&TaskEvent{ID: 2})
+	if te, ok := <-ch; ok {
+		t.Fatalf("got event %+v published after close", te)
+	}
+}
@@ -0,0 +1,139 @@
+package progress
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/morikuni/aec"
+)
+
+// ColorMode controls whether and with how many colors the output is colored.
+type ColorMode int
+
+const (
+	// ColorAuto detects the color support of the terminal from the
+	// environment. NO_COLOR, CLICOLOR, CLICOLOR_FORCE, TERM and COLORTERM are
+	// honored.
+	ColorAuto ColorMode = iota
+
+	// ColorNever never colors the output.
+	ColorNever
+
+	// ColorAlways colors the output even if the environment asks for no
+	// colors, with at least 16 colors.
+	ColorAlways
+
+	// Color16 colors the output with the 16 basic colors.
+	Color16
+
+	// Color256 colors the output with the 256 color palette.
+	Color256
+
+	// ColorTrueColor colors the output with 24 bit colors.
+	ColorTrueColor
+)
+
+// colorLevel is the color support of a terminal.
+type colorLevel int
+
+const (
+	levelNone colorLevel = iota
+	level16
+	level256
+	levelTrueColor
+)
+
+// level returns the color level for the mode.
+func (m ColorMode) level() colorLevel {
+	switch m {
+	case ColorNever:
+		return levelNone
+	case ColorAlways:
+		if l := envColorLevel(); l > level16 {
+			return l
+		}
+		return level16
+	case Color16:
+		return level16
+	case Color256:
+		return level256
+	case ColorTrueColor:
+		return levelTrueColor
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return levelNone
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		if l := envColorLevel(); l > level16 {
+			return l
+		}
+		return level16
+	}
+	if os.Getenv("TERM") == "dumb" || os.Getenv("CLICOLOR") == "0" {
+		return levelNone
+	}
+	return envColorLevel()
+}
+
+// envColorLevel detects the color level from TERM and COLORTERM.
+func envColorLevel() colorLevel {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return levelTrueColor
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return level256
+	}
+	return level16
+}
+
+// RGB returns a 24 bit color. On terminals without support for 24 bit colors
+// the closest supported color is used.
+func RGB(r, g, b uint8) Color {
+	return Color(rgbFlag | uint32(r)<<16 | uint32(g)<<8 | uint32(b))
+}
+
+const rgbFlag = 1 << 24
+
+func (c Color) rgb() (r, g, b uint8, ok bool) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), c&rgbFlag != 0
+}
+
+// ansi returns the escape sequence selecting the foreground color at the
+// given level.
+func (c Color) ansi(level colorLevel) aec.ANSI {
+	r, g, b, isRGB := c.rgb()
+	switch {
+	case c == ColorDefault || level == levelNone:
+		return aec.EmptyBuilder.ANSI
+	case !isRGB:
+		return aec.Color3BitF(aec.RGB3Bit(c - 1))
+	case level == levelTrueColor:
+		return aec.FullColorF(r, g, b)
+	case level == level256:
+		return aec.Color8BitF(aec.NewRGB8Bit(r, g, b))
+	default:
+		return aec.Color3BitF(aec.NewRGB3Bit(r, g, b))
+	}
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(\x07|\x1b\\\\)|\x1b[@-Z\\\\-_]")
+
+// stripANSI removes terminal escape sequences from b.
+func stripANSI(b []byte) []byte {
+	if !containsEscape(b) {
+		return b
+	}
+	return ansiEscape.ReplaceAll(b, nil)
+}
+
+func containsEscape(b []byte) bool {
+	for _, c := range b {
+		if c == 0x1b {
+			return true
+		}
+	}
+	return false
+}
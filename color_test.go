@@ -0,0 +1,55 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorLevel(t *testing.T) {
+	for _, tt := range []struct {
+		mode                                      ColorMode
+		noColor, cliColor, force, term, colorTerm string
+		want                                      colorLevel
+	}{
+		{mode: ColorAuto, term: "xterm", want: level16},
+		{mode: ColorAuto, term: "xterm-256color", want: level256},
+		{mode: ColorAuto, term: "xterm", colorTerm: "truecolor", want: levelTrueColor},
+		{mode: ColorAuto, term: "dumb", want: levelNone},
+		{mode: ColorAuto, term: "xterm", cliColor: "0", want: levelNone},
+		{mode: ColorAuto, term: "xterm-256color", noColor: "1", want: levelNone},
+		{mode: ColorAuto, term: "dumb", force: "1", want: level16},
+		{mode: ColorAuto, term: "xterm-256color", force: "1", want: level256},
+		{mode: ColorAuto, term: "dumb", force: "0", want: levelNone},
+		{mode: ColorAuto, term: "xterm", noColor: "1", force: "1", want: levelNone},
+		{mode: ColorNever, term: "xterm-256color", want: levelNone},
+		{mode: ColorAlways, term: "dumb", noColor: "1", want: level16},
+		{mode: ColorAlways, term: "xterm-256color", want: level256},
+		{mode: Color256, term: "dumb", want: level256},
+		{mode: ColorTrueColor, term: "dumb", want: levelTrueColor},
+	} {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("CLICOLOR", tt.cliColor)
+		t.Setenv("CLICOLOR_FORCE", tt.force)
+		t.Setenv("TERM", tt.term)
+		t.Setenv("COLORTERM", tt.colorTerm)
+		if got := tt.mode.level(); got != tt.want {
+			t.Errorf("%+v: got level %d, want %d", tt, got, tt.want)
+		}
+	}
+}
+
+func TestPlainOutputWithoutEscapes(t *testing.T) {
+	out := display(t, ModePlain, func(rt *RootTask) {
+		_ = rt.Execute("\x1b[31mred\x1b[0m", func(t *Task) error {
+			_, _ = t.Logger().Write([]byte("\x1b[1mbold\x1b[0m log\n"))
+			return nil
+		})
+	})
+
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("plain output contains escape sequences: %q", out)
+	}
+	if !strings.Contains(out, "red") || !strings.Contains(out, "bold log") {
+		t.Errorf("plain output lacks the text: %q", out)
+	}
+}
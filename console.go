@@ -6,29 +6,48 @@ import (
 	"io"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	"github.com/morikuni/aec"
-	"github.com/tonistiigi/units"
 	"github.com/tonistiigi/vt100"
 )
 
+// minNameWidth is the width task names are never shortened below.
+const minNameWidth = 12
+
 type consoleRenderer struct {
-	name      string
-	startTime time.Time
-	tasks     []*task
-	allTasks  map[uint64]*task
-	tasksDone int
-	lines     int
-	hasError  bool
+	name       string
+	startTime  time.Time
+	spinner    Spinner
+	collapse   bool
+	truncation Truncation
+	logLines   int
+	logTail    int
+	tickRate   time.Duration
+	colors     colorLevel
+	theme      *Theme
+	tick       int
+	tasks      []*task
+	allTasks   map[uint64]*task
+	tasksDone  int
+	lines      int
+	width      int
+	hasError   bool
+	canceled   bool
+
+	duplicates duplicateStarts
+	output     []byte // printed above the live area on the next render
 }
 
 func (p *consoleRenderer) update(te *TaskEvent) {
 	if te.ID == 0 {
+		p.output = append(p.output, te.Output...)
 		return
 	}
 
 	if existingTask, ok := p.allTasks[te.ID]; ok {
+		if dup, _ := p.duplicates.check(existingTask.startTime, existingTask.parentID, te); dup {
+			return
+		}
 		existingTask.update(te)
 	} else {
 		parent, hasParent := p.allTasks[te.ParentID]
@@ -45,10 +64,13 @@ func (p *consoleRenderer) update(te *TaskEvent) {
 			ioStartTime: te.IOStartTime,
 			current:     te.Current,
 			total:       te.Total,
-			name:        te.Name,
+			unit:        unitOrDefault(te.Unit),
+			label:       te.Label,
+			name:        string(stripANSI([]byte(te.Name))),
+			isQueued:    te.IsQueued,
 			depth:       depth,
-			term:        vt100.NewVT100(6, 80),
-			logTail:     newTail(32),
+			logLines:    p.logLines,
+			logTail:     newTail(p.logTail),
 			progress:    p,
 		}
 
@@ -58,37 +80,67 @@ func (p *consoleRenderer) update(te *TaskEvent) {
 		} else {
 			p.tasks = append(p.tasks, newTask)
 		}
+		newTask.update(te)
 	}
 }
 
-func (p *consoleRenderer) render(w io.Writer, width int, showError bool) {
-	fmt.Fprint(w, aec.Up(uint(p.lines)))
+func (p *consoleRenderer) render(w io.Writer, width, height int, showError bool) {
+	p.tick = int(time.Since(p.startTime) / p.tickRate)
 
-	left := fmt.Sprintf("+ %s", p.name)
+	left := p.theme.Title + p.name
 	right := fmt.Sprintf("(%d/%d) %.1fs", p.tasksDone, len(p.tasks), time.Since(p.startTime).Seconds())
 	titleLine := align(left, right, width)
 
 	if showError {
 		if p.hasError {
-			titleLine = aec.Apply(titleLine, aec.RedF, aec.Bold)
+			titleLine = p.style(titleLine, p.theme.Colors.Failed)
+		} else if p.canceled {
+			titleLine = p.style(titleLine, p.theme.Colors.Canceled)
 		} else {
-			titleLine = aec.Apply(titleLine, aec.BlueF)
+			titleLine = p.style(titleLine, p.theme.Colors.Done)
 		}
 	}
 
-	fmt.Fprintln(w, titleLine)
-	lineCnt := 1
+	lines := []string{titleLine}
+
+	if n := p.duplicates.count(); n > 0 {
+		warning := fmt.Sprintf("! ignored %d task(s) with duplicate IDs", n)
+		lines = append(lines, p.style(align(warning, "", width), p.theme.Colors.Warning))
+	}
+
+	var blocks []block
 	for _, task := range p.tasks {
-		lineCnt += task.render(w, width, showError)
+		blocks = task.render(width, showError, blocks)
 	}
 
-	if diff := p.lines - lineCnt; diff > 0 {
+	// The final state is printed in full, the live area has to fit on the
+	// screen with one row left for the cursor, or moving up to redraw it
+	// would not reach the top.
+	if showError || height <= 0 {
+		lines = append(lines, flatten(blocks)...)
+	} else {
+		lines = append(lines, p.viewport(blocks, height-1-len(lines), width)...)
+	}
+
+	if p.width != 0 && p.width != width && p.lines > 0 {
+		p.clear(w, width)
+	}
+	p.width = width
+
+	p.printOutput(w, showError)
+
+	fmt.Fprint(w, aec.Up(uint(p.lines)))
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+
+	if diff := p.lines - len(lines); diff > 0 {
 		for i := 0; i < diff; i++ {
 			fmt.Fprintln(w, strings.Repeat(" ", width))
 		}
 		fmt.Fprint(w, aec.Up(uint(diff)))
 	}
-	p.lines = lineCnt
+	p.lines = len(lines)
 
 	if showError {
 		for _, task := range p.tasks {
@@ -97,11 +149,61 @@ func (p *consoleRenderer) render(w io.Writer, width int, showError bool) {
 	}
 }
 
-func newConsoleRenderer(name string) *consoleRenderer {
+// clear erases the live area after the width of the terminal changed to
+// width. When shrinking, terminals reflow the lines printed before, so each
+// of them may occupy multiple rows now.
+func (p *consoleRenderer) clear(w io.Writer, width int) {
+	rows := p.lines
+	if width < p.width {
+		rows *= (p.width + width - 1) / width
+	}
+
+	fmt.Fprint(w, aec.Up(uint(rows)), "\r", aec.EraseDisplay(aec.EraseModes.Tail))
+	p.lines = 0
+}
+
+// printOutput prints the complete lines of the pending output above the live
+// area, which is redrawn below them. Incomplete lines are held back until they
+// are completed or flush is set.
+func (p *consoleRenderer) printOutput(w io.Writer, flush bool) {
+	n := bytes.LastIndexByte(p.output, '\n') + 1
+	if flush && len(p.output) > 0 {
+		if n < len(p.output) {
+			p.output = append(p.output, '\n')
+		}
+		n = len(p.output)
+	}
+	if n == 0 {
+		return
+	}
+
+	if p.lines > 0 {
+		fmt.Fprint(w, aec.Up(uint(p.lines)), "\r", aec.EraseDisplay(aec.EraseModes.Tail))
+		p.lines = 0
+	}
+	_, _ = w.Write(p.output[:n])
+	p.output = p.output[n:]
+}
+
+// style applies the style to s if colors are enabled.
+func (p *consoleRenderer) style(s string, style Style) string {
+	return style.apply(s, p.colors)
+}
+
+func newConsoleRenderer(name string, cfg *config) *consoleRenderer {
 	return &consoleRenderer{
-		name:      name,
-		startTime: time.Now(),
-		allTasks:  make(map[uint64]*task),
+		name:       name,
+		startTime:  time.Now(),
+		spinner:    cfg.spinner,
+		collapse:   cfg.collapseDone,
+		truncation: cfg.truncation,
+		logLines:   cfg.logLines,
+		logTail:    cfg.logTail,
+		tickRate:   cfg.tickRate,
+		colors:     cfg.color.level(),
+		theme:      cfg.theme,
+		allTasks:   make(map[uint64]*task),
+		duplicates: make(duplicateStarts),
 	}
 }
 
@@ -113,14 +215,20 @@ type task struct {
 	startTime, endTime time.Time
 	ioStartTime        time.Time
 	current, total     uint64
+	unit               *Unit
+	label              string
 	displayRate        bool
 	displayETA         bool
 	displayBar         bool
 	isDone             bool
+	isQueued           bool
+	isIncomplete       bool
 	isCached           bool
+	isCanceled         bool
 	hasError           bool
 	err                error
 	logs               [][]byte
+	logLines           int
 	term               *vt100.VT100
 	logTail            *tail
 	subtasks           []*task
@@ -129,11 +237,15 @@ type task struct {
 }
 
 func (t *task) update(te *TaskEvent) {
+	if t.startTime.IsZero() && !te.StartTime.IsZero() {
+		t.startTime = te.StartTime
+		t.isQueued = false
+	}
 	if te.IOStartTime != (time.Time{}) {
 		t.ioStartTime = te.IOStartTime
 	}
 	if te.Name != "" {
-		t.name = te.Name
+		t.name = string(stripANSI([]byte(te.Name)))
 	}
 	t.endTime = te.EndTime
 	if te.Current > 0 {
@@ -142,6 +254,12 @@ func (t *task) update(te *TaskEvent) {
 	if te.Total > 0 {
 		t.total = te.Total
 	}
+	if te.Unit != nil {
+		t.unit = te.Unit
+	}
+	if te.Label != "" {
+		t.label = te.Label
+	}
 	if te.EnableDisplayRate {
 		t.displayRate = true
 	} else if te.DisableDisplayRate {
@@ -168,38 +286,82 @@ func (t *task) update(te *TaskEvent) {
 	if te.Cached {
 		t.isCached = true
 	}
+	if te.IsIncomplete {
+		t.isIncomplete = true
+		t.progress.canceled = true
+	}
 
-	if te.HasErr {
+	if te.IsCanceled {
+		t.isCanceled = true
+		t.err = te.Err
+		t.progress.canceled = true
+	} else if te.HasErr {
 		t.hasError = true
 		t.err = te.Err
 		t.progress.hasError = true
 	}
 
+	if te.LogLines != nil {
+		t.logLines = *te.LogLines
+	}
+	if te.LogTail != nil {
+		t.logTail.setCap(*te.LogTail)
+	}
+
+	// the logs are only kept for the log window of running tasks
+	if t.logLines <= 0 || t.isDone {
+		t.logs = nil
+		t.term = nil
+	}
+
 	if len(te.Logs) > 0 {
-		t.logs = append(t.logs, te.Logs)
+		if t.logLines > 0 && !t.isDone {
+			t.logs = append(t.logs, te.Logs)
+		}
+		if t.progress.colors == levelNone {
+			_, _ = t.logTail.Write(stripANSI(te.Logs))
+		} else {
+			_, _ = t.logTail.Write(te.Logs)
+		}
 	}
 }
 
-func (t *task) render(w io.Writer, width int, showError bool) int {
-	arrow := mkarrow(t.depth)
+// render appends the block of the task and the blocks of all its subtasks to
+// blocks.
+func (t *task) render(width int, showError bool, blocks []block) []block {
+	theme := t.progress.theme
+	arrow := theme.prefix(t.depth)
+
+	symbol := ""
+	if t.isIncomplete {
+		symbol = theme.Incomplete
+	} else if t.isQueued {
+		symbol = theme.Queued
+	} else if t.isCanceled {
+		symbol = theme.Canceled
+	} else if t.hasError {
+		symbol = theme.Failed
+	} else if t.isCached {
+		symbol = theme.Cached
+	} else if t.isDone {
+		symbol = theme.Done
+	} else {
+		symbol = t.progress.spinner.frame(t.progress.tick)
+	}
 
 	cached := ""
-	if t.isCached {
-		cached = " CACHED"
+	if symbol != "" {
+		cached = " " + symbol
 	}
 
 	bytesCount := ""
 	rate := ""
 	eta := ""
 	if t.current > 0 {
-		bytesCount = fmt.Sprintf(" %.1f", units.Bytes(t.current))
-
-		if t.total > 0 && !t.isDone {
-			bytesCount = fmt.Sprintf("%s / %.1f", bytesCount, units.Bytes(t.total))
-		}
+		bytesCount = " " + t.unit.count(t.current, t.total, !t.isDone, 1)
 
 		if t.total > 0 && !t.isDone && t.displayRate {
-			rate = fmt.Sprintf(" (%.1f/s)", units.Bytes(float64(t.current)/time.Since(t.ioStartTime).Seconds()))
+			rate = fmt.Sprintf(" (%s)", t.unit.rate(float64(t.current)/time.Since(t.ioStartTime).Seconds(), t.total, 1))
 		}
 
 		if t.total > 0 && !t.isDone && t.displayETA {
@@ -210,66 +372,115 @@ func (t *task) render(w io.Writer, width int, showError bool) int {
 		}
 	}
 
+	label := ""
+	if t.label != "" && !t.isDone {
+		label = " " + t.label
+	}
+
 	subtasks := ""
 	if len(t.subtasks) > 0 {
 		subtasks = fmt.Sprintf("(%d/%d)", t.subtasksDone, len(t.subtasks))
+
+		queued := 0
+		for _, subtask := range t.subtasks {
+			if subtask.isQueued {
+				queued++
+			}
+		}
+		if queued > 0 {
+			running := len(t.subtasks) - t.subtasksDone - queued
+			subtasks = fmt.Sprintf("(%d/%d, %d running, %d queued)", t.subtasksDone, len(t.subtasks), running, queued)
+		}
 	}
 
 	endTime := time.Now()
 	if t.isDone {
 		endTime = t.endTime
 	}
-	stopwatch := fmt.Sprintf("%.1fs", endTime.Sub(t.startTime).Seconds())
+	stopwatch := "0.0s"
+	if !t.startTime.IsZero() {
+		stopwatch = fmt.Sprintf("%.1fs", endTime.Sub(t.startTime).Seconds())
+	}
 
-	left := fmt.Sprintf("%s%s %s%s%s%s", arrow, cached, t.name, bytesCount, rate, eta)
+	info := bytesCount + rate + eta + label
 	right := fmt.Sprintf("%s %s", stopwatch, subtasks)
 
-	if t.displayBar && t.total > 0 && !t.isDone {
-		barLen := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right) - 2
-		left = fmt.Sprintf("%s %s", left, mkbar(barLen, float64(t.current)/float64(t.total)))
+	avail := width - stringWidth(right) - stringWidth(arrow+cached+info) - 2
+	if avail < minNameWidth {
+		avail = minNameWidth
+	}
+	name := shorten(t.name, avail, theme.Ellipsis, t.progress.truncation)
+
+	left := fmt.Sprintf("%s%s %s%s", arrow, cached, name, info)
+
+	if t.displayBar && !t.isDone {
+		barLen := width - stringWidth(left) - stringWidth(right) - 2
+		if t.total > 0 {
+			left = fmt.Sprintf("%s %s", left, theme.bar(barLen, float64(t.current)/float64(t.total)))
+		} else {
+			left = fmt.Sprintf("%s %s", left, theme.bounce(barLen, t.progress.tick))
+		}
 	}
 
 	titleLine := align(left, right, width)
 
 	if t.hasError {
-		titleLine = aec.Apply(titleLine, aec.RedF, aec.Bold)
+		titleLine = t.progress.style(titleLine, theme.Colors.Failed)
+	} else if t.isCanceled || t.isIncomplete {
+		titleLine = t.progress.style(titleLine, theme.Colors.Canceled)
+	} else if t.isDone && t.isCached {
+		titleLine = t.progress.style(titleLine, theme.Colors.Cached)
 	} else if t.isDone {
-		titleLine = aec.Apply(titleLine, aec.BlueF)
-		if t.isCached {
-			titleLine = aec.Apply(titleLine, aec.Bold)
-		}
+		titleLine = t.progress.style(titleLine, theme.Colors.Done)
 	}
 
-	fmt.Fprintln(w, titleLine)
-	lines := 1
+	b := block{task: t, header: titleLine}
 
-	if !t.isDone {
-		t.term.Resize(6, width)
+	if !t.isDone && t.logLines > 0 {
+		if t.term == nil {
+			t.term = vt100.NewVT100(t.logLines, width)
+		}
+		t.term.Resize(t.logLines, width)
 		mergedLogs := merge(t.logs)
 		_, _ = t.term.Write(mergedLogs)
-		_, _ = t.logTail.Write(mergedLogs)
 		t.logs = nil
 
-		buf := &bytes.Buffer{}
-		renderTerm(t.term, buf)
-		fmt.Fprint(w, aec.Apply(buf.String(), aec.Faint))
-		lines += t.term.UsedHeight()
+		for _, line := range termLines(t.term, width) {
+			b.body = append(b.body, t.progress.style(line, theme.Colors.Logs))
+		}
 	}
 
 	if showError && t.hasError {
-		t.term = vt100.NewVT100(6, width)
-		fmt.Fprintln(t.term, t.err)
-		buf := &bytes.Buffer{}
-		renderTerm(t.term, buf)
-		fmt.Fprint(w, aec.Apply(buf.String(), aec.RedF))
-		lines += t.term.UsedHeight()
+		errTerm := vt100.NewVT100(6, width)
+		fmt.Fprintln(errTerm, t.err)
+		for _, line := range termLines(errTerm, width) {
+			b.body = append(b.body, t.progress.style(line, theme.Colors.ErrorText))
+		}
 	}
 
+	blocks = append(blocks, b)
+	if t.progress.collapse && t.isDone && !t.troubled() {
+		return blocks
+	}
 	for _, subtask := range t.subtasks {
-		lines += subtask.render(w, width, showError)
+		blocks = subtask.render(width, showError, blocks)
 	}
 
-	return lines
+	return blocks
+}
+
+// troubled reports whether the task or any of its subtasks failed, was
+// canceled or is incomplete.
+func (t *task) troubled() bool {
+	if t.hasError || t.isCanceled || t.isIncomplete {
+		return true
+	}
+	for _, subtask := range t.subtasks {
+		if subtask.troubled() {
+			return true
+		}
+	}
+	return false
 }
 
 func (t *task) renderLogs(w io.Writer) {
@@ -278,11 +489,88 @@ func (t *task) renderLogs(w io.Writer) {
 		header := fmt.Sprintf("=== LOG DUMP %s ===", t.name)
 		fmt.Fprintln(logBuf, header)
 		t.logTail.writeTo(logBuf)
-		fmt.Fprintln(logBuf, strings.Repeat("=", utf8.RuneCountInString(header)))
-		fmt.Fprint(w, aec.Apply(logBuf.String(), aec.Faint))
+		fmt.Fprintln(logBuf, strings.Repeat("=", stringWidth(header)))
+		fmt.Fprint(w, t.progress.style(logBuf.String(), t.progress.theme.Colors.Logs))
 	}
 
 	for _, subtask := range t.subtasks {
 		subtask.renderLogs(w)
 	}
 }
+
+// block holds the rendered lines of a single task.
+type block struct {
+	task   *task
+	header string
+	body   []string
+}
+
+// finished reports whether the task of the block completed successfully.
+// Failed, canceled and incomplete tasks are not finished in this sense.
+func (b *block) finished() bool {
+	return b.task.isDone && !b.task.hasError && !b.task.isCanceled && !b.task.isIncomplete
+}
+
+func flatten(blocks []block) []string {
+	var lines []string
+	for _, b := range blocks {
+		lines = append(lines, b.header)
+		lines = append(lines, b.body...)
+	}
+	return lines
+}
+
+// viewport returns at most max lines of blocks. If the blocks do not fit,
+// finished tasks are collapsed into a single line first, then the log windows
+// of the remaining tasks are shrunk. If that is still not enough, the last
+// tasks are cut off.
+func (p *consoleRenderer) viewport(blocks []block, max, width int) []string {
+	if max <= 0 {
+		return nil
+	}
+
+	n := 0
+	for _, b := range blocks {
+		n += 1 + len(b.body)
+	}
+
+	collapsed := 0
+	visible := make([]block, 0, len(blocks))
+	for _, b := range blocks {
+		if n > max && b.finished() {
+			if collapsed == 0 {
+				n++ // line summarizing the collapsed tasks
+			}
+			n -= 1 + len(b.body)
+			collapsed++
+			continue
+		}
+		visible = append(visible, b)
+	}
+
+	for i := range visible {
+		if n <= max {
+			break
+		}
+		drop := n - max
+		if drop > len(visible[i].body) {
+			drop = len(visible[i].body)
+		}
+		visible[i].body = visible[i].body[drop:]
+		n -= drop
+	}
+
+	var lines []string
+	if collapsed > 0 {
+		summary := fmt.Sprintf("%s %d more completed", p.theme.Ellipsis, collapsed)
+		lines = append(lines, p.style(align(summary, "", width), p.theme.Colors.Done))
+	}
+	lines = append(lines, flatten(visible)...)
+
+	if len(lines) > max {
+		cut := len(lines) - max + 1
+		lines = append(lines[:max-1], align(fmt.Sprintf("%s %d more lines", p.theme.Ellipsis, cut), "", width))
+	}
+
+	return lines
+}
@@ -0,0 +1,140 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morikuni/aec"
+)
+
+func TestConsoleDropsHiddenLogs(t *testing.T) {
+	p := newConsoleRenderer("test", newConfig([]Option{WithLogLines(0)}))
+	p.update(&TaskEvent{ID: 1, Name: "a", StartTime: time.Now()})
+	for i := 0; i < 10; i++ {
+		p.update(&TaskEvent{ID: 1, Logs: []byte("line\n")})
+	}
+	if n := len(p.allTasks[1].logs); n != 0 {
+		t.Fatalf("got %d log chunks kept without log window, want 0", n)
+	}
+
+	one := 1
+	p.update(&TaskEvent{ID: 2, Name: "b", StartTime: time.Now(), LogLines: &one})
+	p.update(&TaskEvent{ID: 2, Logs: []byte("line\n")})
+	p.update(&TaskEvent{ID: 2, Logs: []byte("line\n"), IsDone: true})
+	if n := len(p.allTasks[2].logs); n != 0 {
+		t.Fatalf("got %d log chunks kept after the task is done, want 0", n)
+	}
+	if n := p.allTasks[2].logTail.Len(); n != 2 {
+		t.Fatalf("got %d lines in the log tail, want 2", n)
+	}
+
+	p.render(io.Discard, 80, 24, true)
+}
+
+func TestViewportKeepsUnsuccessfulTasks(t *testing.T) {
+	p := newConsoleRenderer("test", newConfig(nil))
+	blocks := []block{
+		{task: &task{isDone: true}, header: "ok"},
+		{task: &task{isDone: true, isCanceled: true}, header: "canceled"},
+		{task: &task{isDone: true, isIncomplete: true}, header: "incomplete"},
+		{task: &task{isDone: true, hasError: true}, header: "failed"},
+		{task: &task{}, header: "running"},
+	}
+
+	lines := p.viewport(blocks, 5, 80)
+	for _, want := range []string{"canceled", "incomplete", "failed"} {
+		found := false
+		for _, l := range lines {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("%q was collapsed, got %q", want, lines)
+		}
+	}
+}
+
+func TestCollapseDone(t *testing.T) {
+	p := newConsoleRenderer("test", newConfig([]Option{WithCollapseDone(true)}))
+	now := time.Now()
+	for _, te := range []*TaskEvent{
+		{ID: 1, Name: "image", StartTime: now},
+		{ID: 2, ParentID: 1, Name: "subimage", StartTime: now},
+		{ID: 3, ParentID: 2, Name: "subsubimage", StartTime: now},
+		{ID: 3, EndTime: now, IsDone: true},
+		{ID: 2, EndTime: now, IsDone: true},
+		{ID: 1, EndTime: now, IsDone: true},
+		{ID: 4, Name: "broken", StartTime: now},
+		{ID: 5, ParentID: 4, Name: "failing", StartTime: now},
+		{ID: 5, EndTime: now, IsDone: true, HasErr: true},
+		{ID: 4, EndTime: now, IsDone: true, HasErr: true},
+		{ID: 6, Name: "running", StartTime: now},
+		{ID: 7, ParentID: 6, Name: "finished", StartTime: now},
+		{ID: 7, EndTime: now, IsDone: true},
+	} {
+		p.update(te)
+	}
+
+	var headers []string
+	for _, task := range p.tasks {
+		for _, b := range task.render(80, false, nil) {
+			headers = append(headers, b.header)
+		}
+	}
+
+	for _, want := range []string{"image", "(1/1)", "broken", "failing", "running", "finished"} {
+		if !strings.Contains(strings.Join(headers, "\n"), want) {
+			t.Errorf("%q is not rendered:\n%s", want, strings.Join(headers, "\n"))
+		}
+	}
+	for _, h := range headers {
+		if strings.Contains(h, "subimage") {
+			t.Errorf("subtree of finished task not collapsed:\n%s", strings.Join(headers, "\n"))
+		}
+	}
+}
+
+func TestConsoleClear(t *testing.T) {
+	for _, tt := range []struct {
+		lines, from, to, rows int
+	}{
+		{5, 100, 30, 20}, // each line reflows into 4 rows
+		{5, 100, 50, 10},
+		{5, 100, 99, 10},
+		{5, 80, 120, 5}, // widening does not reflow
+	} {
+		p := newConsoleRenderer("test", newConfig(nil))
+		p.lines, p.width = tt.lines, tt.from
+
+		buf := &bytes.Buffer{}
+		p.clear(buf, tt.to)
+
+		want := aec.Up(uint(tt.rows)).String() + "\r" + aec.EraseDisplay(aec.EraseModes.Tail).String()
+		if buf.String() != want {
+			t.Errorf("clear %d lines from width %d to %d: got %q, want %q", tt.lines, tt.from, tt.to, buf.String(), want)
+		}
+		if p.lines != 0 {
+			t.Errorf("got %d lines after clear, want 0", p.lines)
+		}
+	}
+}
+
+func TestConsoleFitsWidth(t *testing.T) {
+	p := newConsoleRenderer("a rather long name for the whole build", newConfig(nil))
+	now := time.Now()
+	p.update(&TaskEvent{ID: 1, Name: strings.Repeat("long task name ", 10), StartTime: now})
+	p.update(&TaskEvent{ID: 2, ParentID: 1, Name: "copy", StartTime: now, IOStartTime: now, Total: 100, Current: 40})
+	p.update(&TaskEvent{ID: 2, Logs: []byte(strings.Repeat("log ", 50) + "\n")})
+
+	for _, width := range []int{120, 40, 20} {
+		buf := &bytes.Buffer{}
+		p.render(buf, width, 24, false)
+		for _, line := range strings.Split(string(stripANSI(buf.Bytes())), "\n") {
+			if w := stringWidth(line); w > width {
+				t.Errorf("line of %d cells at width %d: %q", w, width, line)
+			}
+		}
+	}
+}
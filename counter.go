@@ -0,0 +1,64 @@
+package progress
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// CounterTask tracks the progress of processing a number of work items. All
+// methods are safe for concurrent use.
+type CounterTask struct {
+	IOTask
+	count atomic.Uint64
+}
+
+// Inc increments the number of processed items by one.
+func (t *CounterTask) Inc() {
+	t.Add(1)
+}
+
+// Add increments the number of processed items by n.
+func (t *CounterTask) Add(n uint64) {
+	t.s.bus.update(t.id, t.count.Add(n), "", nil)
+}
+
+// SetTotal sets the total number of items, which enables the progress bar and
+// ETA. A total of 0 is ignored, a total once set cannot be removed.
+func (t *CounterTask) SetTotal(total uint64) {
+	t.s.bus.publish(&TaskEvent{
+		ID:    t.id,
+		Total: total,
+	})
+}
+
+// Label sets the label of the item currently processed. It is displayed next
+// to the counter while the task is running.
+func (t *CounterTask) Label(label string) {
+	t.s.bus.update(t.id, 0, label, nil)
+}
+
+// Counter launches a new subtask that counts processed items. The progress is
+// displayed in [UnitItems] by default. If total is 0, the task will not
+// display a progress bar or ETA.
+func (t *Task) Counter(name string, total uint64, f func(*CounterTask) error) error {
+	ct := &CounterTask{IOTask: IOTask{*t.subtask(t.Context(), name, total)}}
+	defer ct.recoverPanic()
+	ct.Unit(UnitItems)
+	err := f(ct)
+	ct.done(err, ct.count.Load())
+	return err
+}
+
+// CounterContext is like [Task.Counter] but passes ctx to f. If ctx is
+// already done, f is not called.
+func (t *Task) CounterContext(ctx context.Context, name string, total uint64, f func(context.Context, *CounterTask) error) error {
+	ct := &CounterTask{IOTask: IOTask{*t.subtask(ctx, name, total)}}
+	defer ct.recoverPanic()
+	ct.Unit(UnitItems)
+	err := ctx.Err()
+	if err == nil {
+		err = f(ctx, ct)
+	}
+	ct.done(err, ct.count.Load())
+	return err
+}
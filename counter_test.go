@@ -0,0 +1,64 @@
+package progress
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	var id uint64
+	_ = rt.Counter("count", 0, func(c *CounterTask) error {
+		id = c.id
+		c.SetTotal(1005)
+		c.Label("first")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.Inc()
+				}
+			}()
+		}
+		wg.Wait()
+		c.Add(5)
+		return nil
+	})
+
+	var label string
+	var total uint64
+	var unit *Unit
+	var last *TaskEvent
+	for _, te := range events() {
+		if te.ID != id {
+			continue
+		}
+		if te.Label != "" {
+			label = te.Label
+		}
+		if te.Total != 0 {
+			total = te.Total
+		}
+		if te.Unit != nil {
+			unit = te.Unit
+		}
+		last = te
+	}
+
+	if label != "first" {
+		t.Errorf("got label %q, want first", label)
+	}
+	if total != 1005 {
+		t.Errorf("got total %d, want 1005", total)
+	}
+	if unit != UnitItems {
+		t.Errorf("got unit %v, want items", unit)
+	}
+	if last == nil || !last.IsDone || last.Current != 1005 {
+		t.Errorf("got last event %+v, want done with 1005 items", last)
+	}
+}
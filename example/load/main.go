@@ -32,12 +32,12 @@ func main() {
 	defer func() { <-done }()
 	defer rt.Close()
 
-	err = rt.Execute("get image", LoadData(ctx))
+	err = rt.ExecuteContext(ctx, "get image", LoadData)
 	if err != nil {
 		return
 	}
 
-	err = rt.Execute("build image", func(t *progress.Task) error {
+	err = rt.ExecuteContext(ctx, "build image", func(ctx context.Context, t *progress.Task) error {
 		return chill(ctx, 10*time.Second)
 	})
 	if err != nil {
@@ -45,57 +45,55 @@ func main() {
 	}
 }
 
-func LoadData(ctx context.Context) func(t *progress.Task) error {
-	return func(t *progress.Task) error {
-		err := t.Copier("load image", 0, func(ct *progress.CopyTask) (loadError error) {
-
-			subtask1 := make(chan error)
-			go func() {
-				subtask1 <- ct.Execute("some subtask", func(t *progress.Task) error {
-					return chill(ctx, 2*time.Second)
-				})
-			}()
-			defer func() {
-				loadError = errors.Join(loadError, <-subtask1)
-			}()
-
-			subtask2 := make(chan error)
-			go func() {
-				subtask2 <- ct.Execute("some subtask", func(t *progress.Task) error {
-					return chill(ctx, 5*time.Second)
-				})
-			}()
-			defer func() {
-				loadError = errors.Join(loadError, <-subtask2)
-			}()
-
-			// ct.DisplayRate(true)
-			// ct.DisplayETA(true)
-			ct.DisplayBar(true)
-			ct.Reset(32 * 1024 * 1024)
-			ct.Name("download image")
-
-			_, err := ct.Copy(io.Discard, rateReader(io.LimitReader(rand.Reader, 32*1024*1024), 4*1024*1024))
-			if err != nil {
-				return fmt.Errorf("failed to download: %w", err)
-			}
-
-			ct.Reset(64 * 1024 * 1024)
-			ct.Name("extract image")
-
-			_, err = ct.Copy(io.Discard, rateReader(io.LimitReader(rand.Reader, 64*1024*1024), 5*1024*1024))
-			if err != nil {
-				return fmt.Errorf("failed to extract: %w", err)
-			}
-
-			return nil
-		})
+func LoadData(ctx context.Context, t *progress.Task) error {
+	err := t.CopierContext(ctx, "load image", 0, func(ctx context.Context, ct *progress.CopyTask) (loadError error) {
+
+		subtask1 := make(chan error)
+		go func() {
+			subtask1 <- ct.Execute("some subtask", func(t *progress.Task) error {
+				return chill(ctx, 2*time.Second)
+			})
+		}()
+		defer func() {
+			loadError = errors.Join(loadError, <-subtask1)
+		}()
+
+		subtask2 := make(chan error)
+		go func() {
+			subtask2 <- ct.Execute("some subtask", func(t *progress.Task) error {
+				return chill(ctx, 5*time.Second)
+			})
+		}()
+		defer func() {
+			loadError = errors.Join(loadError, <-subtask2)
+		}()
+
+		// ct.DisplayRate(true)
+		// ct.DisplayETA(true)
+		ct.DisplayBar(true)
+		ct.Reset(32 * 1024 * 1024)
+		ct.Name("download image")
+
+		_, err := ct.Copy(io.Discard, rateReader(io.LimitReader(rand.Reader, 32*1024*1024), 4*1024*1024))
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to download: %w", err)
+		}
+
+		ct.Reset(64 * 1024 * 1024)
+		ct.Name("extract image")
+
+		_, err = ct.Copy(io.Discard, rateReader(io.LimitReader(rand.Reader, 64*1024*1024), 5*1024*1024))
+		if err != nil {
+			return fmt.Errorf("failed to extract: %w", err)
 		}
 
 		return nil
+	})
+	if err != nil {
+		return err
 	}
+
+	return nil
 }
 
 func rateReader(r io.Reader, maxRate int) io.Reader {
@@ -17,17 +17,19 @@ import (
 var (
 	failWithErr   = false
 	failDownload2 = false
+	mode          = "auto"
 )
 
 func init() {
 	flag.BoolVar(&failWithErr, "fail", false, "fail with error")
 	flag.BoolVar(&failDownload2, "faildl", false, "fail download step with error")
+	flag.StringVar(&mode, "mode", mode, "render mode")
 }
 
 func main() {
 	flag.Parse()
 
-	p, done, err := progress.DisplayProgress(os.Stdout, "build stuff", "auto")
+	p, done, err := progress.Display(os.Stdout, "build stuff", progress.WithMode(progress.Mode(mode)))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to display progress: %v\n", err)
 		os.Exit(1)
@@ -54,52 +56,40 @@ func Work(p *progress.RootTask) (buildError error) {
 
 	fmt.Fprintf(p.Logger(), "starting build\n")
 
-	err := p.Execute("fetch image", func(t *progress.Task) error {
-		errs := make(chan error)
+	g := p.Group("fetch image", 2)
+	for i := 0; i < 3; i++ {
+		idx := i
+		size := uint64(rand.Intn(10000000) + 10000000)
+		g.Go(fmt.Sprintf("fetching %d", idx), func(t *progress.Task) error {
+			return t.Reader("download layer", rand.New(rand.NewSource(0)), size, func(rt *progress.ReaderTask) error {
 
-		for i := 0; i < 3; i++ {
-			go func(idx int) {
-				size := uint64(rand.Intn(10000000) + 10000000)
-				errs <- t.Reader(fmt.Sprintf("fetching %d", idx), rand.New(rand.NewSource(0)), size, func(rt *progress.ReaderTask) error {
-
-					if failDownload2 && idx == 1 {
-						size /= 2
-					}
-
-					if idx == 0 {
-						rt.Cached()
-						return nil
-					}
-
-					lr := rateReader(io.LimitReader(rt, int64(size)), rand.Intn(5300121)+5300121)
-					_, err := io.Copy(io.Discard, lr)
-					if err != nil {
-						return fmt.Errorf("failed to read: %w", err)
-					}
-
-					if failDownload2 && idx == 1 {
-						return errors.New("download err")
-					}
+				if failDownload2 && idx == 1 {
+					size /= 2
+				}
 
+				if idx == 0 {
+					rt.Cached()
 					return nil
-				})
-			}(i)
-		}
+				}
 
-		var allErrs error
-		for i := 0; i < 3; i++ {
-			allErrs = errors.Join(allErrs, <-errs)
-		}
+				lr := rateReader(io.LimitReader(rt, int64(size)), rand.Intn(5300121)+5300121)
+				_, err := io.Copy(io.Discard, lr)
+				if err != nil {
+					return fmt.Errorf("failed to read: %w", err)
+				}
 
-		if allErrs != nil {
-			return fmt.Errorf("failed to fetch image: %w", allErrs)
-		}
+				if failDownload2 && idx == 1 {
+					return errors.New("download err")
+				}
 
-		return nil
-	})
-	if err != nil {
+				return nil
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
 		return fmt.Errorf("failed to fetch image: %w", err)
 	}
+	p.Printf("fetched image with %d layers\n", 3)
 
 	defer func() {
 		if buildError == nil {
@@ -114,7 +104,7 @@ func Work(p *progress.RootTask) (buildError error) {
 		}
 	}()
 
-	err = p.Execute("build image", func(t *progress.Task) error {
+	err := p.Execute("build image", func(t *progress.Task) error {
 		for i := 0; i < 10; i++ {
 			time.Sleep(time.Duration(rand.Intn(100))*time.Millisecond + 50*time.Millisecond)
 			fmt.Fprintf(t.Logger(), "some line %d\n", i)
@@ -146,6 +136,19 @@ func Work(p *progress.RootTask) (buildError error) {
 		return fmt.Errorf("failed to build image: %w", err)
 	}
 
+	err = p.Counter("verify layers", 40, func(t *progress.CounterTask) error {
+		t.DisplayBar(true)
+		for i := 0; i < 40; i++ {
+			t.Label(fmt.Sprintf("layer %d", i))
+			time.Sleep(time.Duration(rand.Intn(50))*time.Millisecond + 10*time.Millisecond)
+			t.Inc()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify layers: %w", err)
+	}
+
 	size := int64(rand.Intn(20000000) + 20000000)
 	err = p.Writer("push image", io.Discard, uint64(size), func(t *progress.WriterTask) error {
 		t.DisplayBar(true)
@@ -0,0 +1,356 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/containerd/console"
+	"github.com/morikuni/aec"
+)
+
+const (
+	altScreenEnter = "\x1b[?1049h"
+	altScreenLeave = "\x1b[?1049l"
+)
+
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyExpand
+	keyFilter
+	keyQuit
+	keyInterrupt
+)
+
+var keySequences = map[string]key{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"k":       keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"j":       keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[H":  keyHome,
+	"\x1b[1~": keyHome,
+	"g":       keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1b[4~": keyEnd,
+	"G":       keyEnd,
+	"\r":      keyExpand,
+	"\n":      keyExpand,
+	" ":       keyExpand,
+	"f":       keyFilter,
+	"q":       keyQuit,
+	"\x03":    keyInterrupt,
+}
+
+// parseKeys translates input read from a terminal in raw mode into keys.
+// Unknown input is skipped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		n, k := 0, keyNone
+		for seq, sk := range keySequences {
+			if len(seq) > n && bytes.HasPrefix(b, []byte(seq)) {
+				n, k = len(seq), sk
+			}
+		}
+
+		if k != keyNone {
+			keys = append(keys, k)
+		} else if n = 1; len(b) > 1 && b[0] == 0x1b && (b[1] == '[' || b[1] == 'O') {
+			// skip unknown escape sequences up to their final byte
+			for n = 2; n < len(b) && (b[n] < 0x40 || b[n] > 0x7e); n++ {
+			}
+			n++
+		}
+
+		if n > len(b) {
+			n = len(b)
+		}
+		b = b[n:]
+	}
+	return keys
+}
+
+// statusFilter selects the tasks listed in fullscreen mode.
+type statusFilter int
+
+const (
+	filterAll statusFilter = iota
+	filterActive
+	filterFailed
+	filterDone
+	filterCount
+)
+
+func (f statusFilter) String() string {
+	return [...]string{"all", "active", "failed", "done"}[f]
+}
+
+func (f statusFilter) match(t *task) bool {
+	failed := t.hasError || t.isCanceled || t.isIncomplete
+	switch f {
+	case filterActive:
+		return !t.isDone
+	case filterFailed:
+		return failed
+	case filterDone:
+		return t.isDone && !failed
+	}
+	return true
+}
+
+// row is a line of the task list in fullscreen mode.
+type row struct {
+	task *task
+	line string
+}
+
+// fullscreenRenderer shows the task tree of a consoleRenderer on the
+// alternate screen of the terminal, which can be navigated with the keyboard.
+// When leaving the alternate screen, the final summary is rendered by the
+// consoleRenderer.
+type fullscreenRenderer struct {
+	p    *consoleRenderer
+	cons console.Console
+
+	entered bool
+	left    bool
+	quit    bool
+	abort   bool
+
+	filter   statusFilter
+	expanded map[*task]bool
+	rows     []row
+	cursor   int
+	selected *task
+	line     int // line of the selected task the cursor is on
+	offset   int // first row on the screen
+	page     int
+}
+
+func newFullscreenRenderer(name string, cfg *config, cons console.Console) *fullscreenRenderer {
+	return &fullscreenRenderer{
+		p:        newConsoleRenderer(name, cfg),
+		cons:     cons,
+		expanded: make(map[*task]bool),
+	}
+}
+
+func (f *fullscreenRenderer) update(te *TaskEvent) {
+	f.p.update(te)
+}
+
+// input handles keys pressed by the user.
+func (f *fullscreenRenderer) input(b []byte) {
+	for _, k := range parseKeys(b) {
+		switch k {
+		case keyUp:
+			f.move(-1)
+		case keyDown:
+			f.move(1)
+		case keyPageUp:
+			f.move(-f.page)
+		case keyPageDown:
+			f.move(f.page)
+		case keyHome:
+			f.move(-len(f.rows))
+		case keyEnd:
+			f.move(len(f.rows))
+		case keyExpand:
+			if f.selected != nil {
+				f.expanded[f.selected] = !f.expanded[f.selected]
+				f.line = 0
+			}
+		case keyFilter:
+			f.filter = (f.filter + 1) % filterCount
+		case keyQuit:
+			f.quit = true
+		case keyInterrupt:
+			f.quit = true
+			f.abort = true
+		}
+	}
+}
+
+func (f *fullscreenRenderer) inputDone() bool {
+	return f.left
+}
+
+// move moves the cursor by n rows.
+func (f *fullscreenRenderer) move(n int) {
+	if len(f.rows) == 0 {
+		return
+	}
+
+	f.cursor += n
+	if f.cursor < 0 {
+		f.cursor = 0
+	} else if f.cursor >= len(f.rows) {
+		f.cursor = len(f.rows) - 1
+	}
+
+	f.selected = f.rows[f.cursor].task
+	f.line = 0
+	for i := f.cursor - 1; i >= 0 && f.rows[i].task == f.selected; i-- {
+		f.line++
+	}
+}
+
+func (f *fullscreenRenderer) render(w io.Writer, width, height int, showError bool) {
+	if f.left {
+		f.p.render(w, width, height, showError)
+		return
+	}
+
+	if showError || f.quit {
+		f.leave(w)
+		if f.abort {
+			interrupt()
+		}
+		f.p.render(w, width, height, showError)
+		return
+	}
+
+	if !f.entered {
+		fmt.Fprint(w, altScreenEnter, aec.Hide)
+		_ = f.cons.SetRaw()
+		f.entered = true
+	}
+
+	if height <= 0 {
+		height = 24
+	}
+	f.page = height - 2
+	f.p.tick = int(time.Since(f.p.startTime) / f.p.tickRate)
+
+	f.rows = f.listRows(width)
+	f.locate()
+
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	} else if f.cursor >= f.offset+f.page {
+		f.offset = f.cursor - f.page + 1
+	}
+	if f.offset > len(f.rows)-f.page {
+		f.offset = len(f.rows) - f.page
+	}
+	if f.offset < 0 {
+		f.offset = 0
+	}
+
+	left := f.p.theme.Title + f.p.name
+	right := fmt.Sprintf("[%s] (%d/%d) %.1fs", f.filter, f.p.tasksDone, len(f.p.tasks), time.Since(f.p.startTime).Seconds())
+	lines := []string{align(left, right, width)}
+
+	for i := f.offset; i < len(f.rows) && i < f.offset+f.page; i++ {
+		line := f.rows[i].line
+		if i == f.cursor {
+			line = aec.Inverse.Apply(pad(string(stripANSI([]byte(line))), width))
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	help := "up/down: move  enter: logs  f: filter  q: inline view"
+	lines = append(lines, f.p.style(align(help, "", width), f.p.theme.Colors.Logs))
+
+	// raw mode may disable the translation of line feeds, so each line is
+	// returned to explicitly. Lines are erased before they are drawn, erasing
+	// after a line filling the whole width would erase its last cell.
+	buf := &bytes.Buffer{}
+	fmt.Fprint(buf, aec.Position(1, 1))
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprint(buf, "\r\n")
+		}
+		fmt.Fprint(buf, aec.EraseLine(aec.EraseModes.Tail), line)
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// leave returns to the normal screen and restores the terminal.
+func (f *fullscreenRenderer) leave(w io.Writer) {
+	if f.entered {
+		fmt.Fprint(w, aec.Show, altScreenLeave)
+		_ = f.cons.Reset()
+	}
+	f.left = true
+}
+
+// listRows lists the rows of all tasks matching the filter. Expanded tasks
+// show their full log buffer and error, others their running logs.
+func (f *fullscreenRenderer) listRows(width int) []row {
+	var blocks []block
+	for _, t := range f.p.tasks {
+		blocks = t.render(width, false, blocks)
+	}
+
+	var rows []row
+	for _, b := range blocks {
+		if !f.filter.match(b.task) {
+			continue
+		}
+		rows = append(rows, row{b.task, b.header})
+
+		body := b.body
+		if f.expanded[b.task] {
+			body = f.fullLogs(b.task, width)
+		}
+		for _, line := range body {
+			rows = append(rows, row{b.task, line})
+		}
+	}
+	return rows
+}
+
+// fullLogs returns the kept logs and the error of t.
+func (f *fullscreenRenderer) fullLogs(t *task, width int) []string {
+	buf := &bytes.Buffer{}
+	t.logTail.writeTo(buf)
+	if t.hasError {
+		fmt.Fprintln(buf, t.err)
+	}
+
+	text := strings.TrimSuffix(string(stripANSI(buf.Bytes())), "\n")
+	if text == "" {
+		return []string{f.p.style(align("(no logs)", "", width), f.p.theme.Colors.Logs)}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, f.p.style(cut(strings.ReplaceAll(line, "\t", "    "), width), f.p.theme.Colors.Logs))
+	}
+	return lines
+}
+
+// locate moves the cursor to the selected line after the rows changed. If
+// the selected task is not listed anymore, the cursor stays in place.
+func (f *fullscreenRenderer) locate() {
+	for i, r := range f.rows {
+		if r.task != f.selected {
+			continue
+		}
+		n := 0
+		for i+n+1 < len(f.rows) && f.rows[i+n+1].task == f.selected && n < f.line {
+			n++
+		}
+		f.cursor = i + n
+		return
+	}
+	f.move(0)
+}
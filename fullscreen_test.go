@@ -0,0 +1,28 @@
+package progress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []key
+	}{
+		{"", nil},
+		{"j", []key{keyDown}},
+		{"\x1b[A\x1bOB", []key{keyUp, keyDown}},
+		{"\x1b[5~\x1b[6~", []key{keyPageUp, keyPageDown}},
+		{"g\x1b[1~G\x1b[4~", []key{keyHome, keyHome, keyEnd, keyEnd}},
+		{"\r \n", []key{keyExpand, keyExpand, keyExpand}},
+		{"fq\x03", []key{keyFilter, keyQuit, keyInterrupt}},
+		{"x\x1b[1;5Cj", []key{keyDown}},
+		{"\x1b[", nil},
+		{"\x1b", nil},
+	} {
+		if got := parseKeys([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
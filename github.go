@@ -0,0 +1,187 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// githubTask tracks the position of a task in the tree, which its
+// annotations are titled with, and whether it is slow or caused a failure.
+// A top-level task also buffers its trace until its group can be opened.
+type githubTask struct {
+	id       uint64
+	parent   *githubTask
+	root     *githubTask
+	name     string
+	started  time.Time
+	cached   bool
+	subtasks int
+	failed   bool
+	done     bool
+
+	failedSubtasks int
+
+	buf bytes.Buffer // buffered lines of a top-level task waiting for its group
+}
+
+// path returns the names of the task and its ancestors.
+func (t *githubTask) path() string {
+	if t.parent == nil {
+		return t.name
+	}
+	return t.parent.path() + " > " + t.name
+}
+
+// githubRenderer renders the trace of each top-level task in a collapsible
+// group of the GitHub Actions log and annotates failed and slow tasks with
+// workflow commands. Groups cannot be nested or interleaved, so only one
+// top-level task is streamed, the others are buffered until it is done.
+type githubRenderer struct {
+	trace *traceRenderer
+
+	slow       time.Duration
+	slowCached time.Duration
+
+	tasks  map[uint64]*githubTask
+	roots  []*githubTask // top-level tasks not printed completely yet
+	active *githubTask   // top-level task whose group is open
+	buf    *bytes.Buffer
+}
+
+func newGitHubRenderer(name string, cfg *config) *githubRenderer {
+	return &githubRenderer{
+		trace:      newTraceRenderer(name),
+		slow:       cfg.slowTask,
+		slowCached: cfg.slowCached,
+		tasks:      make(map[uint64]*githubTask),
+		buf:        bytes.NewBuffer(nil),
+	}
+}
+
+func (g *githubRenderer) update(te *TaskEvent) {
+	if te.ID == 0 {
+		g.buf.Write(te.Output)
+		return
+	}
+
+	t, ok := g.tasks[te.ID]
+	if !ok {
+		t = &githubTask{id: te.ID, name: plainName(te)}
+		if parent, ok := g.tasks[te.ParentID]; ok {
+			t.parent = parent
+			t.root = parent.root
+			parent.subtasks++
+		} else {
+			t.root = t
+			g.roots = append(g.roots, t)
+		}
+		g.tasks[te.ID] = t
+	}
+
+	if t.started.IsZero() {
+		t.started = te.StartTime
+	}
+	t.cached = t.cached || te.Cached
+
+	g.trace.update(te)
+
+	w := &t.root.buf
+	if t.root == g.active {
+		w = g.buf
+	}
+	_, _ = g.trace.buf.WriteTo(w)
+
+	if te.IsDone && !t.done {
+		t.done = true
+		g.annotate(w, t, te)
+	}
+
+	g.schedule()
+}
+
+// annotate writes the annotations of the finished task t. Errors are only
+// reported for the tasks causing them, not for the ancestors failing because
+// of them. Only tasks without subtasks are reported as slow.
+func (g *githubRenderer) annotate(w io.Writer, t *githubTask, te *TaskEvent) {
+	if te.HasErr && !te.IsCanceled {
+		t.failed = true
+		if t.parent != nil {
+			t.parent.failedSubtasks++
+		}
+		if t.failedSubtasks == 0 {
+			fmt.Fprintf(w, "::error title=%s::%s\n", escapeProperty(t.path()), escapeData(failure(te)))
+		}
+		return
+	}
+
+	if t.subtasks > 0 || t.started.IsZero() || te.IsCanceled || te.IsIncomplete {
+		return
+	}
+
+	endTime := te.EndTime
+	if endTime.IsZero() {
+		endTime = time.Now()
+	}
+	d := endTime.Sub(t.started).Round(100 * time.Millisecond)
+
+	if t.cached && g.slowCached > 0 && d >= g.slowCached {
+		fmt.Fprintf(w, "::warning title=%s::cached, but took %s\n", escapeProperty(t.path()), d)
+	} else if !t.cached && g.slow > 0 && d >= g.slow {
+		fmt.Fprintf(w, "::warning title=%s::slow, took %s\n", escapeProperty(t.path()), d)
+	}
+}
+
+// schedule closes the group of the active task once it is done and prints the
+// buffered top-level tasks in order until it reaches one still running, which
+// becomes the active task.
+func (g *githubRenderer) schedule() {
+	if g.active != nil {
+		if !g.active.done {
+			return
+		}
+		fmt.Fprintln(g.buf, "::endgroup::")
+		g.roots = g.roots[1:]
+		g.active = nil
+	}
+
+	for len(g.roots) > 0 {
+		t := g.roots[0]
+		fmt.Fprintf(g.buf, "::group::%s\n", escapeData(t.name))
+		_, _ = t.buf.WriteTo(g.buf)
+		if !t.done {
+			g.active = t
+			return
+		}
+		fmt.Fprintln(g.buf, "::endgroup::")
+		g.roots = g.roots[1:]
+	}
+}
+
+func (g *githubRenderer) render(w io.Writer, _, _ int, done bool) {
+	if done {
+		// tasks still running when the events end are printed anyway
+		for _, t := range g.roots {
+			t.done = true
+		}
+		g.schedule()
+	}
+
+	if g.buf.Len() > 0 {
+		_, _ = w.Write(g.buf.Bytes())
+		g.buf.Reset()
+	}
+}
+
+// escapeData escapes the message of a GitHub Actions workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a GitHub Actions workflow
+// command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,31 @@
+package progress
+
+import "testing"
+
+func TestEscapeData(t *testing.T) {
+	for s, want := range map[string]string{
+		"plain":          "plain",
+		"100%":           "100%25",
+		"a\r\nb":         "a%0D%0Ab",
+		"a: b, c":        "a: b, c",
+		"%0A is escaped": "%250A is escaped",
+	} {
+		if got := escapeData(s); got != want {
+			t.Errorf("escapeData(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	for s, want := range map[string]string{
+		"plain":   "plain",
+		"100%":    "100%25",
+		"a\r\nb":  "a%0D%0Ab",
+		"a: b, c": "a%3A b%2C c",
+		"a > b":   "a > b",
+	} {
+		if got := escapeProperty(s); got != want {
+			t.Errorf("escapeProperty(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/morikuni/aec"
+)
+
+// A running subtask is buffered until it is done, so its section can be kept
+// expanded if it fails. Once its buffered trace exceeds gitlabFlushSize or it
+// has been running for gitlabFlushDelay, it is streamed in a collapsed section
+// instead, so the job log does not lack running output if the job is killed.
+const (
+	gitlabFlushSize  = 16 << 10
+	gitlabFlushDelay = 10 * time.Second
+)
+
+// gitlabEntry is either trace output of a task or one of its subtasks, in
+// the order they occurred.
+type gitlabEntry struct {
+	lines []byte
+	sub   *gitlabTask
+}
+
+// gitlabTask is the section of a task in the job log. Its trace and the
+// sections of its subtasks are kept in order until they are streamed.
+type gitlabTask struct {
+	id                 uint64
+	name               string
+	created            time.Time
+	startTime, endTime time.Time
+	troubled           bool
+	done               bool
+	entries            []gitlabEntry
+
+	opened bool // the section was started in the job log
+	next   int  // index of the first entry not streamed completely
+}
+
+// write appends trace output to the entries of the task.
+func (t *gitlabTask) write(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	if n := len(t.entries); n > 0 && t.entries[n-1].sub == nil {
+		t.entries[n-1].lines = append(t.entries[n-1].lines, p...)
+		return
+	}
+	t.entries = append(t.entries, gitlabEntry{lines: append([]byte(nil), p...)})
+}
+
+// size returns the number of bytes buffered for the task and its subtasks.
+func (t *gitlabTask) size() int {
+	n := 0
+	for _, e := range t.entries[t.next:] {
+		if e.sub != nil {
+			n += e.sub.size()
+		} else {
+			n += len(e.lines)
+		}
+	}
+	return n
+}
+
+// expanded reports whether the section of the task is kept expanded, which
+// is the case if the task or any of its subtasks failed, was canceled or is
+// incomplete.
+func (t *gitlabTask) expanded() bool {
+	if t.troubled {
+		return true
+	}
+	for _, e := range t.entries {
+		if e.sub != nil && e.sub.expanded() {
+			return true
+		}
+	}
+	return false
+}
+
+// start writes the start of the section of the task. Only the sections of
+// finished tasks that did not succeed are expanded.
+func (t *gitlabTask) start(w io.Writer) {
+	t.opened = true
+
+	start := t.startTime
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	collapsed := ""
+	if !t.done || !t.expanded() {
+		collapsed = "[collapsed=true]"
+	}
+	fmt.Fprintf(w, "%ssection_start:%d:task_%d%s\r%s%s\n", aec.EraseLine(aec.EraseModes.Tail), start.Unix(), t.id, collapsed, aec.EraseLine(aec.EraseModes.Tail), t.name)
+}
+
+// end writes the end of the section of the task.
+func (t *gitlabTask) end(w io.Writer) {
+	end := t.endTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	fmt.Fprintf(w, "%ssection_end:%d:task_%d\r%s\n", aec.EraseLine(aec.EraseModes.Tail), end.Unix(), t.id, aec.EraseLine(aec.EraseModes.Tail))
+}
+
+// stream writes the entries of the opened task t in order, as far as they are
+// available. A subtask that is not done stops the stream unless it is due,
+// then its section is started collapsed. If all is set, running tasks are
+// treated as done. stream reports whether t was written completely, so its
+// section can be ended.
+func (t *gitlabTask) stream(w io.Writer, all bool) bool {
+	for ; t.next < len(t.entries); t.next++ {
+		e := &t.entries[t.next]
+		if e.sub == nil {
+			_, _ = w.Write(e.lines)
+			e.lines = nil
+			if t.next == len(t.entries)-1 && !t.done && !all {
+				// more lines may be appended to this entry
+				return false
+			}
+			continue
+		}
+
+		s := e.sub
+		if !s.opened {
+			if !s.done && !all && s.size() < gitlabFlushSize && time.Since(s.created) < gitlabFlushDelay {
+				return false
+			}
+			s.start(w)
+		}
+		if !s.stream(w, all) {
+			return false
+		}
+		s.end(w)
+	}
+
+	return t.done || all
+}
+
+// gitlabRenderer renders the trace of the task tree as nested collapsible
+// sections of the GitLab CI job log. Sections cannot be interleaved, so the
+// task tree is streamed in order, see gitlabFlushSize for how running tasks
+// are handled.
+type gitlabRenderer struct {
+	trace *traceRenderer
+	tasks map[uint64]*gitlabTask
+	root  *gitlabTask // holds the top-level tasks, it has no section
+	buf   *bytes.Buffer
+}
+
+func newGitLabRenderer(name string) *gitlabRenderer {
+	return &gitlabRenderer{
+		trace: newTraceRenderer(name),
+		tasks: make(map[uint64]*gitlabTask),
+		root:  &gitlabTask{opened: true},
+		buf:   bytes.NewBuffer(nil),
+	}
+}
+
+func (g *gitlabRenderer) update(te *TaskEvent) {
+	if te.ID == 0 {
+		g.buf.Write(te.Output)
+		return
+	}
+
+	t, ok := g.tasks[te.ID]
+	if !ok {
+		t = &gitlabTask{id: te.ID, name: plainName(te), created: time.Now()}
+		parent, ok := g.tasks[te.ParentID]
+		if !ok {
+			parent = g.root
+		}
+		parent.entries = append(parent.entries, gitlabEntry{sub: t})
+		g.tasks[te.ID] = t
+	}
+
+	if t.startTime.IsZero() {
+		t.startTime = te.StartTime
+	}
+	if te.IsDone {
+		t.done = true
+		t.endTime = te.EndTime
+		if t.endTime.IsZero() {
+			t.endTime = time.Now()
+		}
+		t.troubled = te.HasErr || te.IsCanceled || te.IsIncomplete
+	}
+
+	g.trace.update(te)
+	t.write(g.trace.buf.Bytes())
+	g.trace.buf.Reset()
+
+	g.root.stream(g.buf, false)
+}
+
+func (g *gitlabRenderer) render(w io.Writer, _, _ int, done bool) {
+	// running tasks become due over time even without new events, when the
+	// events end they are printed anyway
+	g.root.stream(g.buf, done)
+
+	if g.buf.Len() > 0 {
+		_, _ = w.Write(g.buf.Bytes())
+		g.buf.Reset()
+	}
+}
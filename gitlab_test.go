@@ -0,0 +1,76 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitLabSections(t *testing.T) {
+	g := newGitLabRenderer("test")
+	out := &bytes.Buffer{}
+	now := time.Now()
+
+	g.update(&TaskEvent{ID: 1, Name: "a", StartTime: now})
+	g.update(&TaskEvent{ID: 2, ParentID: 1, Name: "b", StartTime: now})
+	g.update(&TaskEvent{ID: 3, ParentID: 1, Name: "c", StartTime: now})
+	g.update(&TaskEvent{ID: 3, Logs: []byte("from c\n")})
+	g.render(out, 80, 24, false)
+	if strings.Contains(out.String(), "task_2") || strings.Contains(out.String(), "from c") {
+		t.Fatalf("running subtasks were not buffered:\n%s", out)
+	}
+
+	g.update(&TaskEvent{ID: 2, IsDone: true, HasErr: true, Err: errors.New("boom"), EndTime: now})
+	g.update(&TaskEvent{ID: 3, IsDone: true, EndTime: now})
+	g.update(&TaskEvent{ID: 1, IsDone: true, HasErr: true, EndTime: now})
+	g.render(out, 80, 24, true)
+
+	s := out.String()
+	if strings.Contains(s, "task_1[collapsed=true]") {
+		t.Errorf("section of task a with a failed subtask is collapsed:\n%s", s)
+	}
+	if strings.Contains(s, "task_2[collapsed=true]") || !strings.Contains(s, "task_2\r") {
+		t.Errorf("section of failed task b is not expanded:\n%s", s)
+	}
+	if !strings.Contains(s, "task_3[collapsed=true]") {
+		t.Errorf("section of task c is not collapsed:\n%s", s)
+	}
+	if i, j := strings.Index(s, "section_end:"+strconv.FormatInt(now.Unix(), 10)+":task_2"), strings.Index(s, "section_start:"+strconv.FormatInt(now.Unix(), 10)+":task_3"); i < 0 || j < i {
+		t.Errorf("sections of b and c are not in order:\n%s", s)
+	}
+	for _, id := range []string{"task_1", "task_2", "task_3"} {
+		if n := strings.Count(s, "section_end:"+strconv.FormatInt(now.Unix(), 10)+":"+id+"\r"); n != 1 {
+			t.Errorf("got %d ends of section %s, want 1", n, id)
+		}
+	}
+}
+
+func TestGitLabStreamsLargeOutput(t *testing.T) {
+	g := newGitLabRenderer("test")
+	out := &bytes.Buffer{}
+
+	g.update(&TaskEvent{ID: 1, Name: "a", StartTime: time.Now()})
+	g.update(&TaskEvent{ID: 2, ParentID: 1, Name: "b", StartTime: time.Now()})
+	line := []byte(strings.Repeat("x", 79) + "\n")
+	for i := 0; i < gitlabFlushSize/len(line)+1; i++ {
+		g.update(&TaskEvent{ID: 2, Logs: line})
+	}
+	g.render(out, 80, 24, false)
+	if !strings.Contains(out.String(), "task_2[collapsed=true]") || !strings.Contains(out.String(), "xxx") {
+		t.Fatalf("output of running task b was not streamed:\n%.500s", out)
+	}
+
+	out.Reset()
+	g.update(&TaskEvent{ID: 2, Logs: []byte("more\n")})
+	g.render(out, 80, 24, true)
+	s := out.String()
+	if !strings.Contains(s, "more") || strings.Contains(s, "xxx") {
+		t.Errorf("streamed output not continued:\n%.500s", s)
+	}
+	if !strings.Contains(s, ":task_2\r") || !strings.Contains(s, ":task_1\r") {
+		t.Errorf("sections of running tasks not ended:\n%s", s)
+	}
+}
@@ -7,7 +7,6 @@ require (
 	github.com/morikuni/aec v1.0.0
 	github.com/tonistiigi/units v0.0.0-20180711220420-6950e57a87ea
 	github.com/tonistiigi/vt100 v0.0.0-20210615222946-8066bb97264f
+	golang.org/x/sys v0.0.0-20210124154548-22da62e12c0c
 	golang.org/x/time v0.3.0
 )
-
-require golang.org/x/sys v0.0.0-20210124154548-22da62e12c0c // indirect
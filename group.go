@@ -0,0 +1,203 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Group runs subtasks concurrently. Subtasks are queued until a slot is free
+// and shown as queued, running or done in the (done/total) column of the
+// group task. Create a Group with [Task.Group] or [Task.GroupContext].
+type Group struct {
+	task   *Task
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	limit  int
+
+	cancelOnError bool
+
+	mu       sync.Mutex
+	running  int
+	queue    []chan struct{} // closed to start the queued subtasks in order
+	errs     []error
+	canceled []error
+}
+
+// Group launches a new subtask that runs the subtasks started with [Group.Go]
+// concurrently. At most limit subtasks run at the same time, if limit is 0 or
+// less there is no limit. [Group.Wait] has to be called to complete the task.
+func (t *Task) Group(name string, limit int) *Group {
+	return t.newGroup(t.Context(), name, limit, false)
+}
+
+// GroupContext is like [Task.Group] but the first failing subtask cancels the
+// returned context, which is passed to all subtasks of the group. Subtasks
+// still queued at that point are not started and marked as canceled.
+func (t *Task) GroupContext(ctx context.Context, name string, limit int) (*Group, context.Context) {
+	g := t.newGroup(ctx, name, limit, true)
+	return g, g.ctx
+}
+
+func (t *Task) newGroup(ctx context.Context, name string, limit int, cancelOnError bool) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+
+	g := &Group{
+		task:          t.subtask(ctx, name, 0),
+		ctx:           ctx,
+		cancel:        cancel,
+		limit:         limit,
+		cancelOnError: cancelOnError,
+	}
+
+	return g
+}
+
+// Go queues a new subtask that calls f once a slot is free. If the context of
+// the group is done before the subtask is started, f is not called and the
+// subtask is marked as canceled.
+func (g *Group) Go(name string, f func(*Task) error) {
+	g.GoContext(name, func(_ context.Context, t *Task) error {
+		return f(t)
+	})
+}
+
+// GoContext is like [Group.Go] but passes the context of the group to f.
+func (g *Group) GoContext(name string, f func(context.Context, *Task) error) {
+	id := g.task.s.ids.NextID()
+	g.task.s.begin(&TaskEvent{
+		ID:       id,
+		ParentID: g.task.id,
+		Name:     name,
+		IsQueued: true,
+	})
+
+	ready := g.enqueue()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		st := &Task{id, g.task.s, g.ctx}
+
+		var err error
+		if g.acquire(ready) {
+			defer g.release()
+
+			now := time.Now()
+			g.task.s.bus.publish(&TaskEvent{
+				ID:          id,
+				StartTime:   now,
+				IOStartTime: now,
+			})
+			defer st.recoverPanic()
+
+			err = f(g.ctx, st)
+		} else {
+			// the subtask was never started, it is only marked as canceled
+			err = g.ctx.Err()
+		}
+		st.done(err, 0)
+
+		if err == nil {
+			return
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		if errors.Is(err, context.Canceled) {
+			g.canceled = append(g.canceled, err)
+			return
+		}
+
+		g.errs = append(g.errs, err)
+		if g.cancelOnError {
+			g.cancel()
+		}
+	}()
+}
+
+// enqueue queues a subtask. The returned channel is closed once a slot is
+// free for it, subtasks are started in the order they were queued.
+func (g *Group) enqueue() chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ready := make(chan struct{})
+	if g.limit <= 0 || g.running < g.limit {
+		g.running++
+		close(ready)
+	} else {
+		g.queue = append(g.queue, ready)
+	}
+	return ready
+}
+
+// acquire waits until ready is closed and reports whether the subtask can be
+// started. If the context of the group is done first, the subtask leaves the
+// queue or passes the slot it got on.
+func (g *Group) acquire(ready chan struct{}) bool {
+	select {
+	case <-ready:
+		if g.ctx.Err() == nil {
+			return true
+		}
+	case <-g.ctx.Done():
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-ready:
+		// the slot was handed over in the meantime
+		g.handOver()
+		return false
+	default:
+	}
+
+	for i, q := range g.queue {
+		if q == ready {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			break
+		}
+	}
+	return false
+}
+
+// release hands the slot of a completed subtask over to the next queued one.
+func (g *Group) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.handOver()
+}
+
+// handOver passes a slot on to the next queued subtask. It must be called with
+// mu held.
+func (g *Group) handOver() {
+	if len(g.queue) == 0 {
+		g.running--
+		return
+	}
+	close(g.queue[0])
+	g.queue = g.queue[1:]
+}
+
+// Wait waits for all subtasks to complete and completes the group task. The
+// errors of all failed subtasks are joined and returned. If subtasks were only
+// canceled, the joined cancellation errors are returned instead.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	err := errors.Join(g.errs...)
+	if err == nil {
+		err = errors.Join(g.canceled...)
+	}
+	g.task.done(err, 0)
+	return err
+}
@@ -0,0 +1,121 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newTestRootTask(t *testing.T) *RootTask {
+	t.Helper()
+	ch := make(chan *TaskEvent)
+	go func() {
+		for range ch {
+		}
+	}()
+	rt := NewRootTask(ch)
+	t.Cleanup(func() { _ = rt.Close() })
+	return rt
+}
+
+// newRecordingRootTask returns a RootTask and a function that closes it and
+// returns all events sent by it.
+func newRecordingRootTask() (*RootTask, func() []*TaskEvent) {
+	ch := make(chan *TaskEvent)
+	done := make(chan []*TaskEvent)
+	go func() {
+		var events []*TaskEvent
+		for te := range ch {
+			events = append(events, te)
+		}
+		done <- events
+	}()
+
+	rt := NewRootTask(ch)
+	return rt, func() []*TaskEvent {
+		_ = rt.Close()
+		return <-done
+	}
+}
+
+func TestGroupStartsInQueueOrder(t *testing.T) {
+	rt := newTestRootTask(t)
+
+	var mu sync.Mutex
+	var order []string
+	g := rt.Group("group", 1)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		name := name
+		g.Go(name, func(*Task) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(order); got != "[a b c d e]" {
+		t.Fatalf("got start order %s, want [a b c d e]", got)
+	}
+}
+
+func TestGroupContextCancelsQueued(t *testing.T) {
+	rt := newTestRootTask(t)
+
+	var mu sync.Mutex
+	var started []string
+	fail := errors.New("fail")
+	g, _ := rt.GroupContext(context.Background(), "group", 1)
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		g.Go(name, func(*Task) error {
+			mu.Lock()
+			started = append(started, name)
+			mu.Unlock()
+			if name == "a" {
+				return fail
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); !errors.Is(err, fail) {
+		t.Fatalf("got error %v, want %v", err, fail)
+	}
+	if got := fmt.Sprint(started); got != "[a]" {
+		t.Fatalf("got started %s, want [a]", got)
+	}
+}
+
+func TestGroupDoesNotStartCanceled(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	g, _ := rt.GroupContext(context.Background(), "group", 1)
+	g.Go("a", func(*Task) error { return errors.New("fail") })
+	g.Go("b", func(*Task) error { return nil })
+	_ = g.Wait()
+
+	var b uint64
+	for _, te := range events() {
+		if te.Name == "b" {
+			b = te.ID
+		}
+		if b == 0 || te.ID != b {
+			continue
+		}
+		if !te.StartTime.IsZero() {
+			t.Errorf("canceled subtask b was started: %+v", te)
+		}
+		if te.IsDone && !te.IsCanceled {
+			t.Errorf("subtask b is not marked as canceled: %+v", te)
+		}
+	}
+	if b == 0 {
+		t.Fatal("no events for subtask b")
+	}
+}
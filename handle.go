@@ -0,0 +1,111 @@
+package progress
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotFinished is the error of task handles that were not finished when
+// the RootTask was closed.
+var ErrNotFinished = errors.New("task was never finished")
+
+// TaskHandle is a task that is not scoped to a function. It is started with
+// [Task.Start] and has to be completed with [TaskHandle.Finish], which makes
+// it suitable for callback-driven code.
+type TaskHandle struct {
+	Task
+	name    string
+	current atomic.Uint64
+	once    sync.Once
+}
+
+// Start launches a new subtask and returns a handle to it. The task keeps
+// running until [TaskHandle.Finish] is called. Handles that are not finished
+// when the RootTask is closed are marked as failed with [ErrNotFinished].
+func (t *Task) Start(name string) *TaskHandle {
+	h := &TaskHandle{
+		Task: *t.subtask(t.Context(), name, 0),
+		name: name,
+	}
+	t.s.track(h)
+	return h
+}
+
+// SetProgress sets the current progress of the task. A progress of 0 is
+// ignored, so progress cannot be reset to 0. Progress set lower than before
+// may not be displayed if both updates are coalesced.
+func (h *TaskHandle) SetProgress(current uint64) {
+	h.current.Store(current)
+	h.s.bus.update(h.id, current, "", nil)
+}
+
+// SetTotal sets the total of the task, which enables the progress bar and
+// ETA. A total of 0 is ignored, a total once set cannot be removed.
+func (h *TaskHandle) SetTotal(total uint64) {
+	h.s.bus.publish(&TaskEvent{
+		ID:    h.id,
+		Total: total,
+	})
+}
+
+// SetUnit sets the unit used to display the progress of the task.
+func (h *TaskHandle) SetUnit(u *Unit) {
+	h.s.bus.publish(&TaskEvent{
+		ID:   h.id,
+		Unit: u,
+	})
+}
+
+// Finish completes the task. If err is not nil, the task will be marked as
+// failed. Only the first call has an effect.
+func (h *TaskHandle) Finish(err error) {
+	h.once.Do(func() {
+		h.s.untrack(h)
+		h.done(err, h.current.Load())
+	})
+}
+
+// track registers an unfinished handle.
+func (s *session) track(h *TaskHandle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.handles == nil {
+		s.handles = make(map[uint64]*TaskHandle)
+	}
+	s.handles[h.id] = h
+}
+
+// untrack removes a finished handle.
+func (s *session) untrack(h *TaskHandle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.handles, h.id)
+}
+
+// finishHandles finishes all unfinished handles with [ErrNotFinished] and
+// returns an error naming them.
+func (s *session) finishHandles() error {
+	s.mu.Lock()
+	handles := make([]*TaskHandle, 0, len(s.handles))
+	for _, h := range s.handles {
+		handles = append(handles, h)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].id < handles[j].id
+	})
+
+	var errs []error
+	for _, h := range handles {
+		h.Finish(ErrNotFinished)
+		errs = append(errs, fmt.Errorf("%q: %w", h.name, ErrNotFinished))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package progress
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleFinish(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	h := rt.Start("handle")
+	h.SetProgress(3)
+	h.Finish(nil)
+	h.Finish(errors.New("ignored"))
+
+	done := 0
+	for _, te := range events() {
+		if te.ID != h.id || !te.IsDone {
+			continue
+		}
+		done++
+		if te.HasErr || te.Current != 3 {
+			t.Errorf("got done event %+v, want success with progress 3", te)
+		}
+	}
+	if done != 1 {
+		t.Errorf("got %d done events, want 1", done)
+	}
+}
+
+func TestCloseFinishesHandles(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	finished := rt.Start("finished")
+	finished.Finish(nil)
+	rt.Start("forgotten")
+
+	err := rt.Close()
+	if !errors.Is(err, ErrNotFinished) {
+		t.Fatalf("got error %v, want %v", err, ErrNotFinished)
+	}
+	if !strings.Contains(err.Error(), `"forgotten"`) || strings.Contains(err.Error(), `"finished"`) {
+		t.Errorf("error %q does not name only the unfinished handle", err)
+	}
+
+	failed := false
+	for _, te := range events() {
+		if te.IsDone && errors.Is(te.Err, ErrNotFinished) {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("unfinished handle not marked as failed")
+	}
+}
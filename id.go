@@ -0,0 +1,28 @@
+package progress
+
+import "sync/atomic"
+
+// IDGenerator allocates task IDs. Implementations must be safe for concurrent
+// use and must never return 0 or the same ID twice.
+type IDGenerator interface {
+	NextID() uint64
+}
+
+// IDGeneratorFunc adapts a function to the [IDGenerator] interface.
+type IDGeneratorFunc func() uint64
+
+// NextID calls f.
+func (f IDGeneratorFunc) NextID() uint64 {
+	return f()
+}
+
+// CounterIDGenerator allocates sequential IDs starting at 1. The zero value is
+// ready to use.
+type CounterIDGenerator struct {
+	n atomic.Uint64
+}
+
+// NextID returns the next ID.
+func (c *CounterIDGenerator) NextID() uint64 {
+	return c.n.Add(1)
+}
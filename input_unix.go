@@ -0,0 +1,59 @@
+//go:build !windows
+
+package progress
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/containerd/console"
+	"golang.org/x/sys/unix"
+)
+
+// readInput sends the input read from f on the returned channel until stop is
+// closed. The returned channel is closed once reading stopped. The file is
+// polled, so no read is pending once stop is closed and no input is taken
+// away from the program afterwards.
+func readInput(f console.File, stop <-chan struct{}) <-chan []byte {
+	input := make(chan []byte)
+
+	go func() {
+		defer close(input)
+
+		fds := []unix.PollFd{{Fd: int32(f.Fd()), Events: unix.POLLIN}}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			n, err := unix.Poll(fds, 100)
+			if err == unix.EINTR || (err == nil && n == 0) {
+				continue
+			} else if err != nil || fds[0].Revents&unix.POLLIN == 0 {
+				return
+			}
+
+			buf := make([]byte, 64)
+			n, err = f.Read(buf)
+			if err != nil {
+				return
+			}
+
+			select {
+			case input <- buf[:n]:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return input
+}
+
+// interrupt sends an interrupt to the process like pressing Ctrl+C would if
+// the terminal was not in raw mode.
+func interrupt() {
+	_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+}
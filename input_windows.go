@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"unsafe"
+
+	"github.com/containerd/console"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32              = windows.NewLazySystemDLL("kernel32.dll")
+	procPeekConsoleInputW = kernel32.NewProc("PeekConsoleInputW")
+	procReadConsoleInputW = kernel32.NewProc("ReadConsoleInputW")
+)
+
+// inputRecord is the INPUT_RECORD of the console API, if EventType is
+// keyEvent, Event holds a KEY_EVENT_RECORD.
+type inputRecord struct {
+	EventType uint16
+	_         uint16
+	Event     [16]byte
+}
+
+const keyEvent = 0x0001
+
+// hasChar reports whether a read of the console input would not block, which
+// is the case if a key was pressed that produces a character.
+func (r *inputRecord) hasChar() bool {
+	keyDown := *(*int32)(unsafe.Pointer(&r.Event[0]))
+	char := *(*uint16)(unsafe.Pointer(&r.Event[10]))
+	return r.EventType == keyEvent && keyDown != 0 && char != 0
+}
+
+// readInput sends the input read from f on the returned channel until stop is
+// closed. The returned channel is closed once reading stopped. The console
+// input is waited on, so no read is pending once stop is closed and no input
+// is taken away from the program afterwards. Input events that are not read
+// as characters, like focus events or releasing a key, are discarded.
+func readInput(f console.File, stop <-chan struct{}) <-chan []byte {
+	input := make(chan []byte)
+
+	go func() {
+		defer close(input)
+
+		h := windows.Handle(f.Fd())
+		records := make([]inputRecord, 16)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			ev, err := windows.WaitForSingleObject(h, 100)
+			if err != nil {
+				return
+			} else if ev != windows.WAIT_OBJECT_0 {
+				continue
+			}
+
+			var n uint32
+			if r, _, _ := procPeekConsoleInputW.Call(uintptr(h), uintptr(unsafe.Pointer(&records[0])), uintptr(len(records)), uintptr(unsafe.Pointer(&n))); r == 0 {
+				return
+			}
+
+			chars := false
+			for i := range records[:n] {
+				chars = chars || records[i].hasChar()
+			}
+			if !chars {
+				// the events would block a read until a key is pressed
+				if r, _, _ := procReadConsoleInputW.Call(uintptr(h), uintptr(unsafe.Pointer(&records[0])), uintptr(n), uintptr(unsafe.Pointer(&n))); r == 0 {
+					return
+				}
+				continue
+			}
+
+			buf := make([]byte, 64)
+			m, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+
+			select {
+			case input <- buf[:m]:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return input
+}
+
+// interrupt sends an interrupt to the process like pressing Ctrl+C would if
+// the console was not in raw mode.
+func interrupt() {
+	_ = windows.GenerateConsoleCtrlEvent(windows.CTRL_C_EVENT, 0)
+}
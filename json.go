@@ -0,0 +1,183 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONVersion is the version of the schema of [JSONEvent]. It is increased
+// when fields change incompatibly, new fields may be added at any time.
+const JSONVersion = 1
+
+// Status values of a [JSONEvent].
+const (
+	StatusQueued     = "queued"     // the task is waiting to be started
+	StatusStarted    = "started"    // the task has been started
+	StatusRunning    = "running"    // the event updates a running task
+	StatusDone       = "done"       // the task completed successfully
+	StatusCached     = "cached"     // the task completed from cache
+	StatusFailed     = "failed"     // the task failed, see Error
+	StatusCanceled   = "canceled"   // the task was canceled
+	StatusIncomplete = "incomplete" // the task was still running when the RootTask was closed
+)
+
+// JSONEvent is a single line of the NDJSON stream written in [ModeJSON]. It
+// carries the same information as the TaskEvent it was written for. Zero
+// values are omitted.
+type JSONEvent struct {
+	Version int       `json:"v"`  // JSONVersion
+	Time    time.Time `json:"ts"` // time the event was written
+
+	ID       uint64 `json:"id"`               // ID of the task, 0 for Output records
+	ParentID uint64 `json:"parent,omitempty"` // ID of the parent task
+	Name     string `json:"name,omitempty"`   // name of the task
+
+	StartTime   *time.Time `json:"start,omitempty"`
+	EndTime     *time.Time `json:"end,omitempty"`
+	IOStartTime *time.Time `json:"io_start,omitempty"`
+
+	Status string `json:"status,omitempty"` // one of the Status constants, empty for Output records
+	Cached bool   `json:"cached,omitempty"` // the task is marked as cached
+
+	Current uint64 `json:"current,omitempty"`
+	Total   uint64 `json:"total,omitempty"`
+	Unit    string `json:"unit,omitempty"`  // name of the unit of Current and Total
+	Label   string `json:"label,omitempty"` // item currently processed
+
+	DisplayRate *bool `json:"rate,omitempty"` // display of the rate was enabled or disabled
+	DisplayETA  *bool `json:"eta,omitempty"`  // display of the ETA was enabled or disabled
+	DisplayBar  *bool `json:"bar,omitempty"`  // display of the bar was enabled or disabled
+
+	// Error is the message of the error of a failed or canceled task as
+	// returned by its Error method.
+	Error string `json:"error,omitempty"`
+
+	// Logs and Output are written as text if they are valid UTF-8, otherwise
+	// base64 encoded to the fields with the _base64 suffix.
+	Logs         string `json:"logs,omitempty"`
+	LogsBase64   []byte `json:"logs_base64,omitempty"`
+	Output       string `json:"output,omitempty"`
+	OutputBase64 []byte `json:"output_base64,omitempty"`
+
+	LogLines *int `json:"log_lines,omitempty"`
+	LogTail  *int `json:"log_tail,omitempty"`
+}
+
+// newJSONEvent converts te to its JSON representation. The status of done
+// tasks is StatusCached if cached is set.
+func newJSONEvent(te *TaskEvent, cached bool, now time.Time) *JSONEvent {
+	je := &JSONEvent{
+		Version:     JSONVersion,
+		Time:        now,
+		ID:          te.ID,
+		ParentID:    te.ParentID,
+		Name:        te.Name,
+		StartTime:   timeOrNil(te.StartTime),
+		EndTime:     timeOrNil(te.EndTime),
+		IOStartTime: timeOrNil(te.IOStartTime),
+		Status:      jsonStatus(te, cached),
+		Cached:      te.Cached,
+		Current:     te.Current,
+		Total:       te.Total,
+		Label:       te.Label,
+		DisplayRate: toggle(te.EnableDisplayRate, te.DisableDisplayRate),
+		DisplayETA:  toggle(te.EnableDisplayETA, te.DisableDisplayETA),
+		DisplayBar:  toggle(te.EnableDisplayBar, te.DisableDisplayBar),
+		LogLines:    te.LogLines,
+		LogTail:     te.LogTail,
+	}
+
+	if te.Unit != nil {
+		je.Unit = te.Unit.Name()
+	}
+	if te.Err != nil {
+		je.Error = te.Err.Error()
+	}
+	je.Logs, je.LogsBase64 = textOrBinary(te.Logs)
+	je.Output, je.OutputBase64 = textOrBinary(te.Output)
+
+	return je
+}
+
+func jsonStatus(te *TaskEvent, cached bool) string {
+	switch {
+	case te.ID == 0:
+		return ""
+	case te.IsDone && te.IsIncomplete:
+		return StatusIncomplete
+	case te.IsDone && te.IsCanceled:
+		return StatusCanceled
+	case te.IsDone && te.HasErr:
+		return StatusFailed
+	case te.IsDone && cached:
+		return StatusCached
+	case te.IsDone:
+		return StatusDone
+	case te.IsQueued:
+		return StatusQueued
+	case !te.StartTime.IsZero():
+		return StatusStarted
+	}
+	return StatusRunning
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// toggle returns whether a display setting was enabled or disabled, or nil if
+// it was left as is.
+func toggle(enable, disable bool) *bool {
+	if !enable && !disable {
+		return nil
+	}
+	return &enable
+}
+
+func textOrBinary(b []byte) (string, []byte) {
+	if utf8.Valid(b) {
+		return string(b), nil
+	}
+	return "", b
+}
+
+type jsonRenderer struct {
+	buf    *bytes.Buffer
+	enc    *json.Encoder
+	cached map[uint64]bool
+}
+
+func newJSONRenderer() *jsonRenderer {
+	buf := &bytes.Buffer{}
+	return &jsonRenderer{
+		buf:    buf,
+		enc:    json.NewEncoder(buf),
+		cached: make(map[uint64]bool),
+	}
+}
+
+func (j *jsonRenderer) update(te *TaskEvent) {
+	if te.ID == 0 && len(te.Output) == 0 {
+		return
+	}
+	if te.Cached {
+		j.cached[te.ID] = true
+	}
+	_ = j.enc.Encode(newJSONEvent(te, j.cached[te.ID], time.Now()))
+	if te.IsDone {
+		delete(j.cached, te.ID)
+	}
+}
+
+func (j *jsonRenderer) render(w io.Writer, _, _ int, _ bool) {
+	if j.buf.Len() > 0 {
+		_, _ = w.Write(j.buf.Bytes())
+		j.buf.Reset()
+	}
+}
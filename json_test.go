@@ -0,0 +1,58 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONEventRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	end := start.Add(1500 * time.Millisecond)
+	lines := 3
+
+	for name, te := range map[string]*TaskEvent{
+		"queued": {ID: 2, ParentID: 1, Name: "queued", IsQueued: true},
+		"started": {
+			ID: 1, Name: "a", StartTime: start, IOStartTime: start,
+			Total: 100, Unit: UnitBytes, LogLines: &lines,
+			EnableDisplayRate: true, DisableDisplayETA: true,
+		},
+		"running": {ID: 1, Current: 42, Label: "item", Logs: []byte("log\n")},
+		"binary":  {ID: 1, Logs: []byte{0xff, 0xfe, '\n'}},
+		"output":  {Output: []byte("printed\n")},
+		"done":    {ID: 1, EndTime: end, IsDone: true, Current: 100},
+		"cached":  {ID: 1, EndTime: end, IsDone: true, Cached: true},
+		"failed": {
+			ID: 1, EndTime: end, IsDone: true, HasErr: true,
+			Err: errors.New("boom"),
+		},
+		"canceled": {
+			ID: 1, EndTime: end, IsDone: true, IsCanceled: true, HasErr: true,
+			Err: errors.New("context canceled"),
+		},
+		"incomplete": {ID: 1, EndTime: end, IsDone: true, IsIncomplete: true},
+	} {
+		b, err := json.Marshal(newJSONEvent(te, te.Cached, end))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var je JSONEvent
+		if err := json.Unmarshal(b, &je); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got := je.taskEvent(func(t time.Time) time.Time { return t })
+		if te.Logs == nil {
+			te.Logs = []byte{}
+		}
+		if te.Output == nil {
+			te.Output = []byte{}
+		}
+		if !reflect.DeepEqual(got, te) {
+			t.Errorf("%s: got %+v, want %+v", name, got, te)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package progress
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Option configures how the progress is displayed.
+type Option func(*config)
+
+// Unlimited can be passed to [WithLogTail] and [Task.LogTail] to keep all log
+// lines.
+const Unlimited = -1
+
+type config struct {
+	mode         Mode
+	tickRate     time.Duration
+	rateLimit    time.Duration
+	defaultWidth int
+	color        ColorMode
+	output       io.Writer
+	spinner      Spinner
+	spinnerSet   bool
+	collapseDone bool
+	truncation   Truncation
+	theme        *Theme
+	logLines     int
+	logTail      int
+	logTailSet   bool
+	replaySpeed  float64
+	slowTask     time.Duration
+	slowCached   time.Duration
+
+	progressInterval time.Duration
+}
+
+const defaultTickRate = 150 * time.Millisecond
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		mode:         ModeAuto,
+		tickRate:     defaultTickRate,
+		rateLimit:    100 * time.Millisecond,
+		defaultWidth: 80,
+		theme:        ThemeClassic,
+		logLines:     6,
+		logTail:      32,
+		replaySpeed:  1,
+		slowTask:     10 * time.Minute,
+		slowCached:   10 * time.Second,
+
+		progressInterval: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.tickRate <= 0 {
+		// the ticker and the animations require a positive interval
+		c.tickRate = defaultTickRate
+	}
+	if !c.spinnerSet {
+		c.spinner = defaultSpinner()
+	}
+	if !c.logTailSet && c.mode == ModeFullscreen {
+		c.logTail = Unlimited
+	}
+
+	return c
+}
+
+// WithMode sets the render mode. The default is [ModeAuto].
+func WithMode(m Mode) Option {
+	return func(c *config) {
+		c.mode = m
+	}
+}
+
+// WithTickRate sets the interval in which the display is refreshed if no
+// events arrive. The default is 150ms, which is also used if d is 0 or less.
+func WithTickRate(d time.Duration) Option {
+	return func(c *config) {
+		c.tickRate = d
+	}
+}
+
+// WithRateLimit sets the minimum interval between two refreshes of the
+// display. The default is 100ms.
+func WithRateLimit(d time.Duration) Option {
+	return func(c *config) {
+		c.rateLimit = d
+	}
+}
+
+// WithDefaultWidth sets the width used if the width of the console cannot be
+// determined. The default is 80.
+func WithDefaultWidth(width int) Option {
+	return func(c *config) {
+		c.defaultWidth = width
+	}
+}
+
+// WithColor sets whether the output is colored. The default is [ColorAuto].
+func WithColor(m ColorMode) Option {
+	return func(c *config) {
+		c.color = m
+	}
+}
+
+// WithOutput sets the writer the progress is rendered to. By default it is
+// rendered to the console.File passed to [Process], which is still used to
+// detect the console and its size.
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// WithTheme sets the theme of the console display. The default is
+// [ThemeClassic].
+func WithTheme(t *Theme) Option {
+	return func(c *config) {
+		c.theme = t
+	}
+}
+
+// WithSpinner sets the frames of the activity indicator displayed next to
+// running tasks. A nil or empty Spinner disables the indicator. By default
+// [SpinnerBraille] is used unless the terminal is dumb or the locale is not
+// UTF-8, then [SpinnerASCII] is used.
+func WithSpinner(s Spinner) Option {
+	return func(c *config) {
+		c.spinner = s
+		c.spinnerSet = true
+	}
+}
+
+// WithCollapseDone enables folding the subtasks of successfully completed
+// tasks into the header line of the task. Subtrees containing failed,
+// canceled or incomplete tasks are always expanded.
+func WithCollapseDone(b bool) Option {
+	return func(c *config) {
+		c.collapseDone = b
+	}
+}
+
+// WithTruncation sets how task names that do not fit on a line are shortened.
+// The default is [TruncateEnd].
+func WithTruncation(t Truncation) Option {
+	return func(c *config) {
+		c.truncation = t
+	}
+}
+
+// WithLogLines sets the number of log lines displayed below running tasks.
+// If n is 0 or less, no logs are displayed. The default is 6. It can be
+// overridden per task with [Task.LogLines].
+func WithLogLines(n int) Option {
+	return func(c *config) {
+		c.logLines = n
+	}
+}
+
+// WithLogTail sets the number of log lines kept for the log dump of failed
+// tasks. Pass [Unlimited] to keep all lines. The default is 32. It can be
+// overridden per task with [Task.LogTail]. In [ModeFullscreen], all lines are
+// kept by default.
+func WithLogTail(n int) Option {
+	return func(c *config) {
+		c.logTail = n
+		c.logTailSet = true
+	}
+}
+
+// WithReplaySpeed sets the factor by which [Replay] speeds up the recorded
+// timing, e.g. 2 replays twice as fast. If factor is 0 or less, all events are
+// replayed at once. The default is 1.
+func WithReplaySpeed(factor float64) Option {
+	return func(c *config) {
+		c.replaySpeed = factor
+	}
+}
+
+// WithSlowTask sets the duration after which tasks are reported as slow in CI
+// modes like [ModeGitHub]. If d is 0, no tasks are reported. The default is 10
+// minutes.
+func WithSlowTask(d time.Duration) Option {
+	return func(c *config) {
+		c.slowTask = d
+	}
+}
+
+// WithSlowCached sets the duration after which cached tasks are reported as
+// suspicious in CI modes like [ModeGitHub], as retrieving results from the
+// cache is expected to be fast. If d is 0, no tasks are reported. The default
+// is 10 seconds.
+func WithSlowCached(d time.Duration) Option {
+	return func(c *config) {
+		c.slowCached = d
+	}
+}
+
+// WithProgressInterval sets the minimum interval between progress reports of
+// a task in [ModeTeamCity]. The default is 5 seconds.
+func WithProgressInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.progressInterval = d
+	}
+}
+
+// Spinner is a set of frames for the activity indicator of running tasks. The
+// frames should all have the same width.
+type Spinner []string
+
+var (
+	// SpinnerASCII works on any terminal.
+	SpinnerASCII = Spinner{"-", "\\", "|", "/"}
+
+	// SpinnerBraille requires a terminal with Unicode support.
+	SpinnerBraille = Spinner{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+)
+
+// frame returns the frame for the given tick.
+func (s Spinner) frame(tick int) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[tick%len(s)]
+}
+
+func defaultSpinner() Spinner {
+	if os.Getenv("TERM") == "dumb" || !isUTF8Locale() {
+		return SpinnerASCII
+	}
+	return SpinnerBraille
+}
+
+func isUTF8Locale() bool {
+	for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			v = strings.ToUpper(v)
+			return strings.Contains(v, "UTF-8") || strings.Contains(v, "UTF8")
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package progress
+
+import "testing"
+
+func TestSpinnerFrame(t *testing.T) {
+	s := Spinner{"a", "b", "c"}
+	for tick, want := range []string{"a", "b", "c", "a", "b"} {
+		if got := s.frame(tick); got != want {
+			t.Errorf("frame(%d) = %q, want %q", tick, got, want)
+		}
+	}
+	if got := Spinner(nil).frame(3); got != "" {
+		t.Errorf("frame of an empty spinner = %q, want none", got)
+	}
+}
+
+func TestDefaultSpinner(t *testing.T) {
+	for _, tt := range []struct {
+		term, lang string
+		want       Spinner
+	}{
+		{"xterm-256color", "en_US.UTF-8", SpinnerBraille},
+		{"xterm-256color", "de_DE.utf8", SpinnerBraille},
+		{"xterm-256color", "C", SpinnerASCII},
+		{"dumb", "en_US.UTF-8", SpinnerASCII},
+	} {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("LC_ALL", "")
+		t.Setenv("LC_CTYPE", "")
+		t.Setenv("LANG", tt.lang)
+		if got := defaultSpinner(); got[0] != tt.want[0] {
+			t.Errorf("TERM=%s LANG=%s: got spinner %q, want %q", tt.term, tt.lang, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package progress
 import (
 	"fmt"
 	"io"
+	"os"
 	"time"
 
 	"github.com/containerd/console"
@@ -11,47 +12,198 @@ import (
 
 type progressRenderer interface {
 	update(te *TaskEvent)
-	render(w io.Writer, width int, showError bool)
+	render(w io.Writer, width, height int, showError bool)
+}
+
+// interactiveRenderer is a progressRenderer handling input from the user.
+type interactiveRenderer interface {
+	progressRenderer
+	input(b []byte)
+
+	// inputDone reports whether the renderer handles no more input.
+	inputDone() bool
+}
+
+// Mode selects how the progress is rendered.
+type Mode string
+
+const (
+	// ModeAuto renders to the console if available and falls back to
+	// ModePlain otherwise.
+	ModeAuto Mode = "auto"
+
+	// ModeTTY renders to the console and fails if it is not available.
+	ModeTTY Mode = "tty"
+
+	// ModePlain renders a plain trace of the events.
+	ModePlain Mode = "plain"
+
+	// ModeFullscreen renders a navigable task tree on the alternate screen
+	// of the console and fails if it is not available. Use the arrow keys or
+	// j and k to move, enter to show the full logs of a task, f to filter the
+	// tasks by status and q to continue in the inline display. When done, the
+	// final summary is printed to the normal screen.
+	ModeFullscreen Mode = "fullscreen"
+
+	// ModeJSON writes each event as a line of JSON, see [JSONEvent].
+	ModeJSON Mode = "json"
+
+	// ModeGitHub renders the trace of each top-level task in a collapsible
+	// group of the GitHub Actions log and annotates failed tasks with their
+	// error and slow tasks with a warning, see [WithSlowTask] and
+	// [WithSlowCached]. It is selected by ModeAuto when running in GitHub
+	// Actions.
+	ModeGitHub Mode = "github"
+
+	// ModeGitLab renders the trace of the task tree as nested collapsible
+	// sections of the GitLab CI job log. The log is streamed in order, a task
+	// is held back until it is done so its section can be expanded if it
+	// failed, was canceled or is incomplete. Tasks running for long or with
+	// much output are streamed in a collapsed section instead. It is selected
+	// by ModeAuto when running in GitLab CI.
+	ModeGitLab Mode = "gitlab"
+
+	// ModeTeamCity renders the task tree as TeamCity service messages. Each
+	// task is a block, the progress of I/O tasks is reported at the interval
+	// set by [WithProgressInterval] and failed tasks are reported with their
+	// error and logs. The errors of failed top-level tasks are reported as
+	// build problems.
+	ModeTeamCity Mode = "teamcity"
+)
+
+// detectMode returns the mode for the CI system the process is running in or
+// ModeAuto if none is detected.
+func detectMode() Mode {
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		return ModeGitHub
+	}
+	if os.Getenv("GITLAB_CI") == "true" {
+		return ModeGitLab
+	}
+	return ModeAuto
 }
 
 // Processes events from a channel and renders them to the console or trace. The
-// mode can be "auto", "tty" or "plain". In "auto" mode, the console is used if
-// available. In "tty" mode, the console is used and an error is returned if it
-// is not available. In "plain" mode, the trace is used.
+// mode can be "auto", "tty", "plain" or one of the other [Mode] values. In
+// "auto" mode, the console is used if available. In "tty" mode, the console is
+// used and an error is returned if it is not available. In "plain" mode, the
+// trace is used. Use [Process] to customize the rendering.
 // When the events channel is closed, the last state is rendered and the
 // function returns. The returned channel is closed when the rendering is
 // complete.
 // You'll most likely want to use [DisplayProgress] instead of this function.
+//
+// ProcessEvents is a shorthand for [Process] with [WithMode].
 func ProcessEvents(f console.File, name, mode string, events <-chan *TaskEvent) (<-chan struct{}, error) {
+	return Process(f, name, events, WithMode(Mode(mode)))
+}
+
+// Process processes events from a channel and renders them to f as
+// configured by opts, see [Option] for the available settings and their
+// defaults. When the events channel is closed, the last state is rendered and
+// the returned channel is closed.
+// You'll most likely want to use [Display] instead of this function.
+func Process(f console.File, name string, events <-chan *TaskEvent, opts ...Option) (<-chan struct{}, error) {
+	cfg := newConfig(opts)
+
+	var out io.Writer = f
+	if cfg.output != nil {
+		out = cfg.output
+	}
 
 	var renderer progressRenderer = newTraceRenderer(name)
 	var cons console.Console = noopConsole{}
 
+	mode := cfg.mode
+	if mode == ModeAuto {
+		mode = detectMode()
+	}
+
 	switch mode {
-	case "auto", "tty":
+	case ModeAuto, ModeTTY:
 		if c, err := console.ConsoleFromFile(f); err == nil {
 			cons = c
-			renderer = newConsoleRenderer(name)
-		} else if mode == "tty" {
+			renderer = newConsoleRenderer(name, cfg)
+		} else if mode == ModeTTY {
+			return nil, fmt.Errorf("failed to open console: %s", err)
+		}
+
+	case ModeFullscreen:
+		c, err := console.ConsoleFromFile(f)
+		if err != nil {
 			return nil, fmt.Errorf("failed to open console: %s", err)
 		}
+		cons = c
+		renderer = newFullscreenRenderer(name, cfg, c)
+
+	case ModeJSON:
+		renderer = newJSONRenderer()
+
+	case ModeGitHub:
+		renderer = newGitHubRenderer(name, cfg)
 
-	case "plain":
+	case ModeGitLab:
+		renderer = newGitLabRenderer(name)
+
+	case ModeTeamCity:
+		renderer = newTeamCityRenderer(cfg)
+
+	case ModePlain:
 	default:
-		return nil, fmt.Errorf("unknown mode %q", mode)
+		return nil, fmt.Errorf("unknown mode %q", cfg.mode)
+	}
+
+	var resized <-chan struct{}
+	stopResize := func() {}
+	if _, ok := cons.(noopConsole); !ok {
+		resized, stopResize = notifyResize()
+	}
+
+	// keys are read from the console input, f is the output
+	var input, reader <-chan []byte
+	stopInput := make(chan struct{})
+	interactive, ok := renderer.(interactiveRenderer)
+	if ok {
+		if in, err := console.ConsoleFromFile(os.Stdin); err == nil {
+			reader = readInput(in, stopInput)
+			input = reader
+		}
 	}
 
-	tickRate := 150 * time.Millisecond
-	rateLimit := 100 * time.Millisecond
+	// stopReader stops the reader and waits for it to exit. It must not run
+	// anymore when the program reads from the console itself, otherwise it
+	// could consume its input.
+	stopReader := func() {
+		if reader == nil {
+			return
+		}
+		close(stopInput)
+		for range reader {
+		}
+		reader, input = nil, nil
+	}
 
-	t := time.NewTicker(tickRate)
-	r := rate.NewLimiter(rate.Every(rateLimit), 1)
+	t := time.NewTicker(cfg.tickRate)
+	r := rate.NewLimiter(rate.Every(cfg.rateLimit), 1)
 	doneChan := make(chan struct{})
 
 	go func() {
+		defer stopResize()
+
 		for done := false; !done; {
+			force := false
 			select {
 			case <-t.C:
+			case <-resized:
+				force = true
+			case b, ok := <-input:
+				if !ok {
+					// reading failed, the renderer stays without input
+					input = nil
+					continue
+				}
+				interactive.input(b)
+				force = true
 			case e, ok := <-events:
 				if !ok {
 					done = true
@@ -60,17 +212,23 @@ func ProcessEvents(f console.File, name, mode string, events <-chan *TaskEvent)
 				}
 			}
 
-			if done || r.Allow() {
+			if done || force || r.Allow() {
 				size, err := cons.Size()
-				if err != nil {
-					size = console.WinSize{Width: 80}
+				if err != nil || size.Width == 0 {
+					size = console.WinSize{Width: uint16(cfg.defaultWidth)}
 				}
 
-				renderer.render(f, int(size.Width), done)
+				renderer.render(out, int(size.Width), int(size.Height), done)
 				t.Stop()
-				t = time.NewTicker(tickRate)
+				t = time.NewTicker(cfg.tickRate)
+
+				if reader != nil && interactive.inputDone() {
+					stopReader()
+				}
 			}
 		}
+
+		stopReader()
 		close(doneChan)
 	}()
 
@@ -0,0 +1,112 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// devNull returns a file that is not a console.
+func devNull(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func TestDisplayDone(t *testing.T) {
+	for _, mode := range []Mode{ModePlain, ModeJSON, ModeGitHub, ModeGitLab, ModeTeamCity} {
+		out := &bytes.Buffer{}
+		rt, done, err := Display(devNull(t), "test", WithMode(mode), WithOutput(out))
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		_ = rt.Execute("task", func(*Task) error { return nil })
+		_ = rt.Close()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: rendering did not complete", mode)
+		}
+		if out.Len() == 0 {
+			t.Errorf("%s: nothing rendered", mode)
+		}
+	}
+}
+
+func TestNonPositiveTickRate(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if cfg := newConfig([]Option{WithTickRate(d)}); cfg.tickRate != defaultTickRate {
+			t.Errorf("WithTickRate(%s) results in tick rate %s, want %s", d, cfg.tickRate, defaultTickRate)
+		}
+	}
+}
+
+func TestProcessModes(t *testing.T) {
+	if _, err := Process(devNull(t), "test", make(chan *TaskEvent), WithMode("bogus")); err == nil {
+		t.Error("unknown mode accepted")
+	}
+	if _, err := Process(devNull(t), "test", make(chan *TaskEvent), WithMode(ModeTTY)); err == nil {
+		t.Error("ModeTTY accepted without a console")
+	}
+
+	// without a console, ModeAuto falls back to the trace
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITLAB_CI", "")
+	out := display(t, ModeAuto, func(rt *RootTask) {
+		_ = rt.Execute("task", func(*Task) error { return nil })
+	})
+	if !strings.Contains(out, `DONE "task"`) {
+		t.Errorf("got output %q, want the trace", out)
+	}
+}
+
+func TestDetectMode(t *testing.T) {
+	for _, tt := range []struct {
+		github, gitlab string
+		want           Mode
+	}{
+		{"", "", ModeAuto},
+		{"true", "", ModeGitHub},
+		{"", "true", ModeGitLab},
+		{"false", "false", ModeAuto},
+	} {
+		t.Setenv("GITHUB_ACTIONS", tt.github)
+		t.Setenv("GITLAB_CI", tt.gitlab)
+		if got := detectMode(); got != tt.want {
+			t.Errorf("GITHUB_ACTIONS=%q GITLAB_CI=%q: got mode %q, want %q", tt.github, tt.gitlab, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayProgress(t *testing.T) {
+	rt, done, err := DisplayProgress(devNull(t), "test", "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rt.Close()
+	<-done
+
+	if _, _, err := DisplayProgress(devNull(t), "test", "bogus"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := newConfig(nil)
+	if cfg.mode != ModeAuto || cfg.tickRate != defaultTickRate || cfg.logLines != 6 || cfg.logTail != 32 {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+	if cfg := newConfig([]Option{WithMode(ModeFullscreen)}); cfg.logTail != Unlimited {
+		t.Errorf("got log tail %d in fullscreen mode, want unlimited", cfg.logTail)
+	}
+	if cfg := newConfig([]Option{WithMode(ModeFullscreen), WithLogTail(5)}); cfg.logTail != 5 {
+		t.Errorf("got log tail %d, want 5", cfg.logTail)
+	}
+}
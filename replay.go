@@ -0,0 +1,156 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/containerd/console"
+)
+
+// Replay reads an event stream recorded in [ModeJSON] from r and renders it
+// to f like [Process]. The events are replayed with their original timing,
+// use [WithReplaySpeed] to speed it up or replay all events at once. Replay
+// returns when the rendering is complete.
+func Replay(r io.Reader, f console.File, name string, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	events := make(chan *TaskEvent)
+	done, err := Process(f, name, events, opts...)
+	if err != nil {
+		return err
+	}
+
+	err = replay(r, events, cfg.replaySpeed)
+	close(events)
+	<-done
+
+	return err
+}
+
+// replay sends the events read from r to ch. The times of the recording are
+// shifted to now and scaled by speed, so the durations and rates displayed
+// match the replayed timing. If speed is 0 or less, the times are only shifted
+// and the events are sent without delay.
+func replay(r io.Reader, ch chan<- *TaskEvent, speed float64) error {
+	dec := json.NewDecoder(r)
+	start := time.Now()
+	var origin time.Time
+
+	shift := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+		d := t.Sub(origin)
+		if speed > 0 {
+			d = time.Duration(float64(d) / speed)
+		}
+		return start.Add(d)
+	}
+
+	for {
+		var je JSONEvent
+		if err := dec.Decode(&je); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read event: %w", err)
+		}
+
+		if je.Version != JSONVersion {
+			return fmt.Errorf("unsupported event version %d", je.Version)
+		}
+
+		if origin.IsZero() {
+			origin = je.Time
+		}
+		if speed > 0 {
+			time.Sleep(time.Until(shift(je.Time)))
+		}
+
+		ch <- je.taskEvent(shift)
+	}
+}
+
+// taskEvent converts je back to a TaskEvent. Times are converted by shift.
+func (je *JSONEvent) taskEvent(shift func(time.Time) time.Time) *TaskEvent {
+	te := &TaskEvent{
+		ID:           je.ID,
+		ParentID:     je.ParentID,
+		Name:         je.Name,
+		IsQueued:     je.Status == StatusQueued,
+		Cached:       je.Cached || je.Status == StatusCached,
+		Current:      je.Current,
+		Total:        je.Total,
+		Label:        je.Label,
+		IsCanceled:   je.Status == StatusCanceled,
+		IsIncomplete: je.Status == StatusIncomplete,
+		Logs:         []byte(je.Logs),
+		Output:       []byte(je.Output),
+		LogLines:     je.LogLines,
+		LogTail:      je.LogTail,
+	}
+
+	for _, t := range []struct {
+		from *time.Time
+		to   *time.Time
+	}{
+		{je.StartTime, &te.StartTime},
+		{je.EndTime, &te.EndTime},
+		{je.IOStartTime, &te.IOStartTime},
+	} {
+		if t.from != nil {
+			*t.to = shift(*t.from)
+		}
+	}
+
+	switch je.Status {
+	case StatusDone, StatusCached, StatusFailed, StatusCanceled, StatusIncomplete:
+		te.IsDone = true
+	}
+
+	if je.Status == StatusFailed || je.Error != "" {
+		te.HasErr = true
+		te.Err = errors.New(je.Error)
+	}
+
+	if je.Unit != "" {
+		te.Unit = unitByName(je.Unit)
+	}
+
+	if len(je.LogsBase64) > 0 {
+		te.Logs = je.LogsBase64
+	}
+	if len(je.OutputBase64) > 0 {
+		te.Output = je.OutputBase64
+	}
+
+	te.EnableDisplayRate, te.DisableDisplayRate = untoggle(je.DisplayRate)
+	te.EnableDisplayETA, te.DisableDisplayETA = untoggle(je.DisplayETA)
+	te.EnableDisplayBar, te.DisableDisplayBar = untoggle(je.DisplayBar)
+
+	return te
+}
+
+// untoggle is the inverse of toggle.
+func untoggle(b *bool) (enable, disable bool) {
+	if b == nil {
+		return false, false
+	}
+	return *b, !*b
+}
+
+// unitByName returns the predefined unit with the given name. For custom
+// units, whose formatting is not recorded, the plain number is followed by
+// the name.
+func unitByName(name string) *Unit {
+	for _, u := range []*Unit{UnitBytes, UnitItems, UnitPercent} {
+		if u.name == name {
+			return u
+		}
+	}
+	return NewUnit(name, func(v float64) string {
+		return UnitItems.format(v, 1) + " " + name
+	})
+}
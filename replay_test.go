@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	recording := display(t, ModeJSON, func(rt *RootTask) {
+		_ = rt.Execute("build", func(t *Task) error {
+			_, _ = t.Logger().Write([]byte("compiling\n"))
+			return t.Execute("test", func(*Task) error {
+				return errors.New("boom")
+			})
+		})
+		rt.Printf("printed\n")
+	})
+
+	out := &bytes.Buffer{}
+	err := Replay(strings.NewReader(recording), devNull(t), "replay", WithMode(ModePlain), WithOutput(out), WithReplaySpeed(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := -1
+	for _, want := range []string{`START "build"`, "replay: compiling", `START "test"`, `DONE "test"`, "with ERR boom", "printed\n"} {
+		i := strings.Index(out.String(), want)
+		if i <= last {
+			t.Fatalf("%q is not in order:\n%s", want, out)
+		}
+		last = i
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	recording := `{"v":1,"ts":"2024-01-01T00:00:00Z","id":1,"name":"a","start":"2024-01-01T00:00:00Z","status":"started"}
+{"v":1,"ts":"2024-01-01T00:00:02Z","id":1,"end":"2024-01-01T00:00:02Z","status":"done"}
+`
+	ch := make(chan *TaskEvent, 2)
+	start := time.Now()
+	if err := replay(strings.NewReader(recording), ch, 20); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	first, second := <-ch, <-ch
+	if d := second.EndTime.Sub(first.StartTime); d != 100*time.Millisecond {
+		t.Errorf("got replayed duration %s, want 100ms", d)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("replay took %s, want about 100ms", elapsed)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	for name, recording := range map[string]string{
+		"version":   `{"v":2,"ts":"2024-01-01T00:00:00Z","id":1}`,
+		"malformed": `{"v":1,`,
+	} {
+		ch := make(chan *TaskEvent, 1)
+		if err := replay(strings.NewReader(recording), ch, 0); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package progress
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize signals on the returned channel when the terminal is resized.
+// The returned function stops the notifications.
+func notifyResize() (<-chan struct{}, func()) {
+	sig := make(chan os.Signal, 1)
+	resized := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	signal.Notify(sig, syscall.SIGWINCH)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return resized, func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
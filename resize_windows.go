@@ -0,0 +1,9 @@
+//go:build windows
+
+package progress
+
+// notifyResize is not supported on Windows, the size of the console is still
+// polled on every frame.
+func notifyResize() (<-chan struct{}, func()) {
+	return nil, func() {}
+}
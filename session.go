@@ -0,0 +1,148 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when writing to a task after its RootTask has been
+// closed.
+var ErrClosed = errors.New("root task is closed")
+
+// session holds the state shared by all tasks of a RootTask.
+type session struct {
+	bus *eventBus
+	ids IDGenerator
+
+	closeOnce sync.Once
+	rendered  <-chan struct{} // closed when the final state is rendered, may be nil
+
+	mu      sync.Mutex
+	closed  bool
+	running map[uint64]struct{}    // running and queued tasks by ID
+	idle    chan struct{}          // closed while no task is running
+	handles map[uint64]*TaskHandle // unfinished task handles by ID
+}
+
+func newSession(bus *eventBus, ids IDGenerator) *session {
+	idle := make(chan struct{})
+	close(idle)
+
+	return &session{
+		bus:     bus,
+		ids:     ids,
+		running: make(map[uint64]struct{}),
+		idle:    idle,
+	}
+}
+
+// begin publishes the first event of a task and tracks the task as running
+// until end is called.
+func (s *session) begin(te *TaskEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if len(s.running) == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.running[te.ID] = struct{}{}
+	s.bus.publish(te)
+}
+
+// end publishes the last event of a task and stops tracking it.
+func (s *session) end(te *TaskEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if _, ok := s.running[te.ID]; ok {
+		delete(s.running, te.ID)
+		if len(s.running) == 0 {
+			close(s.idle)
+		}
+	}
+	s.bus.publish(te)
+}
+
+func (s *session) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// wait blocks until no task is running or ctx is done.
+func (s *session) wait(ctx context.Context) error {
+	s.mu.Lock()
+	idle := s.idle
+	s.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown finishes the unfinished task handles and closes the session once.
+func (s *session) shutdown() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.finishHandles()
+		s.close()
+	})
+	return err
+}
+
+// abort shuts the session down and waits for the final state to be rendered.
+func (s *session) abort() {
+	_ = s.shutdown()
+	if s.rendered != nil {
+		<-s.rendered
+	}
+}
+
+// close marks all running tasks as incomplete and closes the event bus. Later
+// events are discarded.
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	ids := make([]uint64, 0, len(s.running))
+	for id := range s.running {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	now := time.Now()
+	for _, id := range ids {
+		s.bus.publish(&TaskEvent{
+			ID:           id,
+			EndTime:      now,
+			IsDone:       true,
+			IsIncomplete: true,
+		})
+	}
+
+	if len(s.running) > 0 {
+		s.running = nil
+		close(s.idle)
+	}
+	s.bus.close()
+}
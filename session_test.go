@@ -0,0 +1,98 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCloseMarksRunningIncomplete(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	running := make(chan *Task)
+	release := make(chan struct{})
+	go func() {
+		_ = rt.Execute("running", func(t *Task) error {
+			running <- t
+			<-release
+			return nil
+		})
+	}()
+	task := <-running
+
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Close(); err != nil {
+		t.Fatalf("second Close returned %v", err)
+	}
+	if _, err := task.Logger().Write([]byte("late\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v writing after Close, want %v", err, ErrClosed)
+	}
+	if _, err := rt.Stdout().Write([]byte("late\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v printing after Close, want %v", err, ErrClosed)
+	}
+	close(release)
+
+	incomplete := 0
+	for _, te := range events() {
+		if te.ID != task.id || !te.IsDone {
+			continue
+		}
+		if !te.IsIncomplete {
+			t.Errorf("got done event %+v after Close, want incomplete", te)
+		}
+		incomplete++
+	}
+	if incomplete != 1 {
+		t.Errorf("got %d done events, want 1", incomplete)
+	}
+}
+
+func TestCloseWait(t *testing.T) {
+	rt, events := newRecordingRootTask()
+	defer events()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = rt.Execute("task", func(*Task) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rt.CloseWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+
+}
+
+func TestCloseWaitCompletes(t *testing.T) {
+	rt, events := newRecordingRootTask()
+
+	started := make(chan struct{})
+	go func() {
+		_ = rt.Execute("task", func(*Task) error {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-started
+
+	if err := rt.CloseWait(context.Background()); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	for _, te := range events() {
+		if te.IsIncomplete {
+			t.Errorf("task marked incomplete although CloseWait waited: %+v", te)
+		}
+	}
+}
@@ -11,6 +11,8 @@ type tail struct {
 	cap   int
 }
 
+// newTail creates a tail keeping the last maxLines lines. If maxLines is
+// negative, all lines are kept.
 func newTail(maxLines int) *tail {
 	return &tail{
 		cap:   maxLines,
@@ -33,13 +35,23 @@ func (t *tail) Write(p []byte) (int, error) {
 		}
 
 		t.lines.PushBack(clone)
-		if t.lines.Len() > t.cap {
-			t.lines.Remove(t.lines.Front())
-		}
+		t.trim()
 	}
 	return len(p), nil
 }
 
+// setCap changes the number of lines kept and drops excess lines.
+func (t *tail) setCap(maxLines int) {
+	t.cap = maxLines
+	t.trim()
+}
+
+func (t *tail) trim() {
+	for t.cap >= 0 && t.lines.Len() > t.cap {
+		t.lines.Remove(t.lines.Front())
+	}
+}
+
 func (t *tail) writeTo(w io.Writer) {
 	for e := t.lines.Front(); e != nil; e = e.Next() {
 		_, _ = w.Write(e.Value.([]byte))
@@ -1,7 +1,11 @@
 package progress
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"runtime/debug"
 	"time"
 
 	"github.com/containerd/console"
@@ -12,17 +16,63 @@ type RootTask struct {
 	Task
 }
 
-// Close closes the channel of events.
+// Close closes the channel of events once all pending events have been
+// delivered. Task handles that were not finished are marked as failed and
+// reported in the returned error, all other tasks still running are marked as
+// incomplete. Events of tasks after Close are discarded. Calling Close more
+// than once has no effect.
 func (r *RootTask) Close() error {
-	close(r.ch)
-	return nil
+	return r.s.shutdown()
+}
+
+// CloseWait waits for all running tasks to complete before closing the
+// RootTask like [RootTask.Close]. If ctx is done first, the RootTask is closed
+// anyway and the context error is returned along with the error of Close.
+func (r *RootTask) CloseWait(ctx context.Context) error {
+	err := r.s.wait(ctx)
+	return errors.Join(err, r.Close())
+}
+
+// NextID allocates a new task ID from the ID generator of the RootTask. Use
+// this if you send your own TaskEvents alongside the Task interfaces so the
+// IDs do not collide.
+func (r *RootTask) NextID() uint64 {
+	return r.s.ids.NextID()
+}
+
+// Stdout returns an io.Writer whose output is printed permanently above the
+// live progress area instead of corrupting it. In plain mode, the output is
+// passed through as is. After the RootTask has been closed, ErrClosed is
+// returned.
+func (r *RootTask) Stdout() io.Writer {
+	return &outputWriter{r.s}
+}
+
+// Printf formats according to a format specifier and prints the result
+// above the live progress area like [RootTask.Stdout].
+func (r *RootTask) Printf(format string, a ...any) {
+	fmt.Fprintf(r.Stdout(), format, a...)
 }
 
 // NewRootTask creates a new RootTask that sends events to the given channel.
+// Task IDs are allocated sequentially starting at 1.
+//
+// Sending events never blocks the tasks. Events are queued until they can be
+// delivered to ch, progress updates and logs of a task are coalesced while
+// queued.
 func NewRootTask(ch chan *TaskEvent) *RootTask {
+	return NewRootTaskWithIDGenerator(ch, &CounterIDGenerator{})
+}
+
+// NewRootTaskWithIDGenerator creates a new RootTask that sends events to the
+// given channel and allocates task IDs using ids.
+func NewRootTaskWithIDGenerator(ch chan *TaskEvent, ids IDGenerator) *RootTask {
+	bus := newEventBus()
+	go bus.forward(ch)
+
 	return &RootTask{
 		Task{
-			ch: ch,
+			s: newSession(bus, ids),
 		},
 	}
 }
@@ -33,21 +83,31 @@ func NewRootTask(ch chan *TaskEvent) *RootTask {
 // close the RootTask after all Subtasks are completed. After the RootTask is
 // closed, the remaining unprocesses events are rendered and the returned
 // channel is closed.
+//
+// DisplayProgress is a shorthand for [Display] with [WithMode].
 func DisplayProgress(f console.File, name, mode string) (*RootTask, <-chan struct{}, error) {
+	return Display(f, name, WithMode(Mode(mode)))
+}
+
+// Display displays progress events like [DisplayProgress] as configured by
+// opts.
+func Display(f console.File, name string, opts ...Option) (*RootTask, <-chan struct{}, error) {
 	events := make(chan *TaskEvent)
 
-	done, err := ProcessEvents(f, name, mode, events)
+	done, err := Process(f, name, events, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return NewRootTask(events), done, nil
+	rt := NewRootTask(events)
+	rt.s.rendered = done
+	return rt, done, nil
 }
 
 // TaskEvent carries all the information about tasks. You'll only need this if
 // you do not want to use the Task interfaces and provide the events yourself.
 type TaskEvent struct {
-	ID       uint64 // unique ID for the task, must be > 0
+	ID       uint64 // unique ID for the task, must be > 0 unless the event only carries Output
 	ParentID uint64 // ID of the parent task, 0 if no parent
 
 	Name string // name of the task, this will be displayed in the header line
@@ -55,12 +115,16 @@ type TaskEvent struct {
 	StartTime, EndTime time.Time // start and end time of the task, used to calculate the duration
 	IOStartTime        time.Time // start time of the IO task, used to calculate the rate and ETA
 	IsDone             bool      // true if the task is done, finished tasks will be displayed differently
+	IsQueued           bool      // true if the task is waiting to be started, a later event with StartTime set starts it
+	IsIncomplete       bool      // true if the task was still running when the RootTask was closed
 
 	Cached bool // true if the task is cached, cached tasks will be displayed differently when they are done
 
 	// Current and Total are used to display a copy progress if Total is
 	// unknown leave it as 0 and only Current will be displayed
 	Current, Total uint64
+	Unit           *Unit  // unit of Current and Total, UnitBytes is used if nil
+	Label          string // label of the item currently processed, displayed while the task is running
 
 	EnableDisplayRate  bool // true if displaying the rate should be enabled, only used for io tasks
 	DisableDisplayRate bool // true if displaying the rate should be disabled, only used for io tasks
@@ -70,82 +134,179 @@ type TaskEvent struct {
 	DisableDisplayETA bool // true if displaying the ETA should be disabled, only used for io tasks
 	DisableDisplayBar bool // true if displaying the bar should be disabled, only used for io tasks
 
-	HasErr bool  // true if the task has an error
-	Err    error // error of the task, will be displayed in the task body when all tasks are done
+	HasErr     bool  // true if the task has an error
+	Err        error // error of the task, will be displayed in the task body when all tasks are done
+	IsCanceled bool  // true if the task was canceled, canceled tasks are displayed differently from failed ones
 
 	Logs []byte // logs of the task, will be displayed in the task body
+
+	Output []byte // output printed above the progress, only used if ID is 0
+
+	LogLines *int // number of log lines displayed while the task is running, nil to keep the current setting
+	LogTail  *int // number of log lines kept for the log dump if the task fails, nil to keep the current setting
 }
 
 // TaskLogger implements io.Writer and writes logs to the task.
 type TaskLogger struct {
-	ch chan *TaskEvent
+	s  *session
 	id uint64
 }
 
-// Write writes logs to the task.
+// Write writes logs to the task. After the RootTask has been closed, ErrClosed
+// is returned.
 func (l *TaskLogger) Write(p []byte) (int, error) {
-	pp := make([]byte, len(p))
-	copy(pp, p)
-	l.ch <- &TaskEvent{
-		ID:   l.id,
-		Logs: pp,
+	if l.s.isClosed() {
+		return 0, ErrClosed
 	}
+
+	l.s.bus.update(l.id, 0, "", p)
+	return len(p), nil
+}
+
+// outputWriter implements io.Writer for [RootTask.Stdout].
+type outputWriter struct {
+	s *session
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	if o.s.isClosed() {
+		return 0, ErrClosed
+	}
+
+	o.s.bus.publish(&TaskEvent{Output: append([]byte(nil), p...)})
 	return len(p), nil
 }
 
 // Task is the base type for all tasks. It provides the basic functionality
 // for tasks like logging and launching subtasks.
 type Task struct {
-	id uint64
-	ch chan *TaskEvent
+	id  uint64
+	s   *session
+	ctx context.Context
+}
+
+// Context returns the context of the task. Subtasks launched with [Task.Execute]
+// inherit this context, so canceling it propagates down the task tree. For
+// tasks not launched by one of the context variants, the context of the
+// parent task is returned or context.Background() for the root task.
+func (t *Task) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
 }
 
 // Logger returns a *TaskLogger that can be used to write logs to the task.
 func (t *Task) Logger() *TaskLogger {
-	return &TaskLogger{t.ch, t.id}
+	return &TaskLogger{t.s, t.id}
 }
 
 // Name sets the name of the task.
 func (t *Task) Name(name string) {
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:   t.id,
 		Name: name,
-	}
+	})
+}
+
+// LogLines sets the number of log lines displayed while the task is running.
+// If n is 0 or less, no logs are displayed, which is useful for noisy tasks.
+func (t *Task) LogLines(n int) {
+	t.s.bus.publish(&TaskEvent{
+		ID:       t.id,
+		LogLines: &n,
+	})
+}
+
+// LogTail sets the number of log lines kept for the log dump if the task
+// fails. Pass [Unlimited] to keep all lines.
+func (t *Task) LogTail(n int) {
+	t.s.bus.publish(&TaskEvent{
+		ID:      t.id,
+		LogTail: &n,
+	})
 }
 
 // Execute launches a new subtask by calling the given function and waits for
 // it to complete. If f returns an error, the task will be marked as failed and
-// the error will be returned.
+// the error will be returned. If f panics, the task will be marked as failed,
+// the display is completed and the panic is resumed.
 func (t *Task) Execute(name string, f func(*Task) error) error {
-	newID := uint64(time.Now().UnixNano())
+	st := t.subtask(t.Context(), name, 0)
+	defer st.recoverPanic()
+	err := f(st)
+	st.done(err, 0)
+	return err
+}
+
+// ExecuteContext is like [Task.Execute] but passes ctx to f. If ctx is
+// already done, f is not called. If f returns an error wrapping
+// context.Canceled, the task will be marked as canceled instead of failed.
+func (t *Task) ExecuteContext(ctx context.Context, name string, f func(context.Context, *Task) error) error {
+	st := t.subtask(ctx, name, 0)
+	defer st.recoverPanic()
+	err := ctx.Err()
+	if err == nil {
+		err = f(ctx, st)
+	}
+	st.done(err, 0)
+	return err
+}
+
+// subtask announces a new subtask and returns it.
+func (t *Task) subtask(ctx context.Context, name string, total uint64) *Task {
+	newID := t.s.ids.NextID()
 	now := time.Now()
-	t.ch <- &TaskEvent{
+	t.s.begin(&TaskEvent{
 		ID:          newID,
 		ParentID:    t.id,
 		Name:        name,
+		Total:       total,
 		StartTime:   now,
 		IOStartTime: now,
-	}
+	})
+
+	return &Task{newID, t.s, ctx}
+}
+
+// done marks the task as done. If current is 0, the progress is left as is.
+func (t *Task) done(err error, current uint64) {
+	t.s.end(&TaskEvent{
+		ID:         t.id,
+		EndTime:    time.Now(),
+		Current:    current,
+		IsDone:     true,
+		HasErr:     err != nil,
+		Err:        err,
+		IsCanceled: errors.Is(err, context.Canceled),
+	})
+}
 
-	err := f(&Task{newID, t.ch})
+// recoverPanic has to be deferred by the functions launching a subtask. If
+// the task panics, it is marked as failed with the panic value and the stack
+// trace in its logs. Then the RootTask is closed and the final state rendered
+// before the panic is resumed.
+func (t *Task) recoverPanic() {
+	v := recover()
+	if v == nil {
+		return
+	}
 
-	t.ch <- &TaskEvent{
-		ID:      newID,
-		EndTime: time.Now(),
-		IsDone:  true,
-		HasErr:  err != nil,
-		Err:     err,
+	if !t.s.isClosed() {
+		t.s.bus.update(t.id, 0, "", []byte(fmt.Sprintf("panic: %v\n\n%s", v, debug.Stack())))
+		t.done(fmt.Errorf("panic: %v", v), 0)
+		t.s.abort()
 	}
 
-	return err
+	panic(v)
 }
 
 // Cached marks the task as cached.
 func (t *Task) Cached() {
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:     t.id,
 		Cached: true,
-	}
+	})
 }
 
 // IOTask is a task that can be used to display IO progress.
@@ -157,33 +318,41 @@ type IOTask struct {
 func (t *IOTask) DisplayRate(b bool) {
 	EnableDisplayRate := b
 	DisableDisplayRate := !b
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:                 t.id,
 		EnableDisplayRate:  EnableDisplayRate,
 		DisableDisplayRate: DisableDisplayRate,
-	}
+	})
 }
 
 // DisplayETA enables or disables the display of the ETA.
 func (t *IOTask) DisplayETA(b bool) {
 	EnableDisplayETA := b
 	DisableDisplayETA := !b
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:                t.id,
 		EnableDisplayETA:  EnableDisplayETA,
 		DisableDisplayETA: DisableDisplayETA,
-	}
+	})
 }
 
 // DisplayBar enables or disables the display of a progress bar.
 func (t *IOTask) DisplayBar(b bool) {
 	EnableDisplayBar := b
 	DisableDisplayBar := !b
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:                t.id,
 		EnableDisplayBar:  EnableDisplayBar,
 		DisableDisplayBar: DisableDisplayBar,
-	}
+	})
+}
+
+// Unit sets the unit used to display the progress of the task.
+func (t *IOTask) Unit(u *Unit) {
+	t.s.bus.publish(&TaskEvent{
+		ID:   t.id,
+		Unit: u,
+	})
 }
 
 // ReaderTask tracks the progress of reading from an underlying io.Reader
@@ -193,15 +362,17 @@ type ReaderTask struct {
 	r    io.Reader
 }
 
-// Read reads from the underlying reader and updates the progress.
+// Read reads from the underlying reader and updates the progress. If the
+// context of the task is done, the context error is returned instead.
 func (t *ReaderTask) Read(p []byte) (int, error) {
+	if err := t.Context().Err(); err != nil {
+		return 0, err
+	}
+
 	n, err := t.r.Read(p)
 
 	t.read += uint64(n)
-	t.ch <- &TaskEvent{
-		ID:      t.id,
-		Current: t.read,
-	}
+	t.s.bus.update(t.id, t.read, "", nil)
 
 	return n, err
 }
@@ -209,29 +380,23 @@ func (t *ReaderTask) Read(p []byte) (int, error) {
 // Reader launches a new subtask that reads from the given reader. If total is
 // 0, the task will not display a progress bar or ETA.
 func (t *Task) Reader(name string, r io.Reader, total uint64, f func(*ReaderTask) error) error {
-	newID := uint64(time.Now().UnixNano())
-	now := time.Now()
-	t.ch <- &TaskEvent{
-		ID:          newID,
-		ParentID:    t.id,
-		Name:        name,
-		Total:       total,
-		StartTime:   now,
-		IOStartTime: now,
-	}
-
-	rt := &ReaderTask{IOTask{Task{newID, t.ch}}, 0, r}
-
+	rt := &ReaderTask{IOTask{*t.subtask(t.Context(), name, total)}, 0, r}
+	defer rt.recoverPanic()
 	err := f(rt)
+	rt.done(err, rt.read)
+	return err
+}
 
-	t.ch <- &TaskEvent{
-		ID:      newID,
-		EndTime: time.Now(),
-		Current: rt.read,
-		IsDone:  true,
-		HasErr:  err != nil,
-		Err:     err,
+// ReaderContext is like [Task.Reader] but passes ctx to f. Reads fail once ctx
+// is done. If ctx is already done, f is not called.
+func (t *Task) ReaderContext(ctx context.Context, name string, r io.Reader, total uint64, f func(context.Context, *ReaderTask) error) error {
+	rt := &ReaderTask{IOTask{*t.subtask(ctx, name, total)}, 0, r}
+	defer rt.recoverPanic()
+	err := ctx.Err()
+	if err == nil {
+		err = f(ctx, rt)
 	}
+	rt.done(err, rt.read)
 	return err
 }
 
@@ -242,15 +407,17 @@ type WriterTask struct {
 	w       io.Writer
 }
 
-// Write writes to the underlying writer and updates the progress.
+// Write writes to the underlying writer and updates the progress. If the
+// context of the task is done, the context error is returned instead.
 func (t *WriterTask) Write(p []byte) (int, error) {
+	if err := t.Context().Err(); err != nil {
+		return 0, err
+	}
+
 	n, err := t.w.Write(p)
 
 	t.written += uint64(n)
-	t.ch <- &TaskEvent{
-		ID:      t.id,
-		Current: t.written,
-	}
+	t.s.bus.update(t.id, t.written, "", nil)
 
 	return n, err
 }
@@ -258,30 +425,23 @@ func (t *WriterTask) Write(p []byte) (int, error) {
 // Writer launches a new subtask that writes to the given writer. If total is
 // 0, the task will not display a progress bar or ETA.
 func (t *Task) Writer(name string, w io.Writer, total uint64, f func(*WriterTask) error) error {
-	newID := uint64(time.Now().UnixNano())
-	now := time.Now()
-	t.ch <- &TaskEvent{
-		ID:          newID,
-		ParentID:    t.id,
-		Name:        name,
-		Total:       total,
-		StartTime:   now,
-		IOStartTime: now,
-	}
-
-	wt := &WriterTask{IOTask{Task{newID, t.ch}}, 0, w}
-
+	wt := &WriterTask{IOTask{*t.subtask(t.Context(), name, total)}, 0, w}
+	defer wt.recoverPanic()
 	err := f(wt)
+	wt.done(err, wt.written)
+	return err
+}
 
-	t.ch <- &TaskEvent{
-		ID:      newID,
-		EndTime: time.Now(),
-		Current: wt.written,
-		IsDone:  true,
-		HasErr:  err != nil,
-		Err:     err,
+// WriterContext is like [Task.Writer] but passes ctx to f. Writes fail once
+// ctx is done. If ctx is already done, f is not called.
+func (t *Task) WriterContext(ctx context.Context, name string, w io.Writer, total uint64, f func(context.Context, *WriterTask) error) error {
+	wt := &WriterTask{IOTask{*t.subtask(ctx, name, total)}, 0, w}
+	defer wt.recoverPanic()
+	err := ctx.Err()
+	if err == nil {
+		err = f(ctx, wt)
 	}
-
+	wt.done(err, wt.written)
 	return err
 }
 
@@ -291,14 +451,13 @@ type CopyTask struct {
 	written uint64
 }
 
-// Copy copies from src to dest and updates the progress.
+// Copy copies from src to dest and updates the progress. The copy is aborted
+// once the context of the task is done.
 func (t *CopyTask) Copy(dest io.Writer, src io.Reader) (int64, error) {
 	r := &countReader{
+		ctx: t.Context(),
 		notify: func(i int64) {
-			t.ch <- &TaskEvent{
-				ID:      t.id,
-				Current: uint64(i),
-			}
+			t.s.bus.update(t.id, uint64(i), "", nil)
 		},
 		r: src,
 	}
@@ -309,40 +468,33 @@ func (t *CopyTask) Copy(dest io.Writer, src io.Reader) (int64, error) {
 // Reset resets the progress of the task, this is useful if you want to reuse
 // the same task for multiple copies.
 func (t *CopyTask) Reset(total uint64) {
-	t.ch <- &TaskEvent{
+	t.s.bus.publish(&TaskEvent{
 		ID:          t.id,
 		Total:       total,
 		Current:     0,
 		IOStartTime: time.Now(),
-	}
+	})
 }
 
 // Copier launches a new subtask that can be used to copy from an io.Reader to
 // an io.Writer. If total is 0, the task will not display a progress bar or ETA.
 func (t *Task) Copier(name string, total uint64, f func(*CopyTask) error) error {
-	newID := uint64(time.Now().UnixNano())
-	now := time.Now()
-	t.ch <- &TaskEvent{
-		ID:          newID,
-		ParentID:    t.id,
-		Name:        name,
-		Total:       total,
-		StartTime:   now,
-		IOStartTime: now,
-	}
-
-	ct := &CopyTask{IOTask{Task{newID, t.ch}}, 0}
-
+	ct := &CopyTask{IOTask{*t.subtask(t.Context(), name, total)}, 0}
+	defer ct.recoverPanic()
 	err := f(ct)
+	ct.done(err, ct.written)
+	return err
+}
 
-	t.ch <- &TaskEvent{
-		ID:      newID,
-		EndTime: time.Now(),
-		Current: ct.written,
-		IsDone:  true,
-		HasErr:  err != nil,
-		Err:     err,
+// CopierContext is like [Task.Copier] but passes ctx to f. Copies are aborted
+// once ctx is done. If ctx is already done, f is not called.
+func (t *Task) CopierContext(ctx context.Context, name string, total uint64, f func(context.Context, *CopyTask) error) error {
+	ct := &CopyTask{IOTask{*t.subtask(ctx, name, total)}, 0}
+	defer ct.recoverPanic()
+	err := ctx.Err()
+	if err == nil {
+		err = f(ctx, ct)
 	}
-
+	ct.done(err, ct.written)
 	return err
 }
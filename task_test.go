@@ -0,0 +1,162 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteRepanics(t *testing.T) {
+	out := &bytes.Buffer{}
+	rt, done, err := Display(devNull(t), "test", WithMode(ModePlain), WithOutput(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := make(chan any)
+	go func() {
+		defer func() { recovered <- recover() }()
+		_ = rt.Execute("task", func(*Task) error {
+			panic("boom")
+		})
+	}()
+
+	select {
+	case v := <-recovered:
+		if v != "boom" {
+			t.Fatalf("got panic %v, want boom", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic was not propagated")
+	}
+
+	select {
+	case <-done:
+	default:
+		t.Fatal("final state not rendered before the panic was propagated")
+	}
+	if s := out.String(); !strings.Contains(s, "panic: boom") {
+		t.Errorf("panic not rendered:\n%s", s)
+	}
+}
+
+// display runs f with a RootTask rendered in mode and returns the output.
+func display(t *testing.T, mode Mode, f func(rt *RootTask), opts ...Option) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	rt, done, err := Display(devNull(t), "test", append([]Option{WithMode(mode), WithOutput(out)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f(rt)
+	_ = rt.Close()
+	<-done
+	return out.String()
+}
+
+func TestCanceledIsNotFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	out := display(t, ModePlain, func(rt *RootTask) {
+		err := rt.ExecuteContext(ctx, "canceled", func(context.Context, *Task) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+		_ = rt.Execute("wrapped", func(*Task) error {
+			return fmt.Errorf("stopped: %w", context.Canceled)
+		})
+		_ = rt.Execute("failed", func(*Task) error {
+			return errors.New("boom")
+		})
+	})
+
+	if called {
+		t.Error("f was called with a done context")
+	}
+	for _, want := range []string{`CANCELED "canceled"`, `CANCELED "wrapped"`, `DONE "failed"`, "with ERR boom"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "with ERR") != 1 {
+		t.Errorf("canceled tasks reported as failed:\n%s", out)
+	}
+}
+
+func TestConsoleCanceledSymbol(t *testing.T) {
+	p := newConsoleRenderer("test", newConfig([]Option{WithTheme(ThemeUnicode)}))
+	now := time.Now()
+	p.update(&TaskEvent{ID: 1, Name: "canceled", StartTime: now})
+	p.update(&TaskEvent{ID: 1, EndTime: now, IsDone: true, HasErr: true, IsCanceled: true, Err: context.Canceled})
+	p.update(&TaskEvent{ID: 2, Name: "failed", StartTime: now})
+	p.update(&TaskEvent{ID: 2, EndTime: now, IsDone: true, HasErr: true, Err: errors.New("boom")})
+
+	theme := p.theme
+	if h := p.allTasks[1].render(80, false, nil)[0].header; !strings.Contains(h, theme.Canceled) || strings.Contains(h, theme.Failed) {
+		t.Errorf("canceled task rendered as %q", h)
+	}
+	if h := p.allTasks[2].render(80, false, nil)[0].header; !strings.Contains(h, theme.Failed) || strings.Contains(h, theme.Canceled) {
+		t.Errorf("failed task rendered as %q", h)
+	}
+	if !p.hasError || !p.canceled {
+		t.Errorf("got hasError %v, canceled %v, want both", p.hasError, p.canceled)
+	}
+}
+
+func TestStdoutOrderInPlainMode(t *testing.T) {
+	out := display(t, ModePlain, func(rt *RootTask) {
+		rt.Printf("before %d\n", 1)
+		_ = rt.Execute("task", func(*Task) error {
+			_, _ = rt.Stdout().Write([]byte("during\n"))
+			return nil
+		})
+		rt.Printf("after\n")
+	})
+
+	last := -1
+	for _, want := range []string{"before 1\n", `START "task"`, "during\n", `DONE "task"`, "after\n"} {
+		i := strings.Index(out, want)
+		if i <= last {
+			t.Fatalf("%q is not in order:\n%s", want, out)
+		}
+		last = i
+	}
+}
+
+func TestConsoleOutputAboveLiveArea(t *testing.T) {
+	p := newConsoleRenderer("test", newConfig(nil))
+	p.update(&TaskEvent{ID: 1, Name: "task", StartTime: time.Now()})
+
+	buf := &bytes.Buffer{}
+	p.update(&TaskEvent{Output: []byte("par")})
+	p.render(buf, 80, 24, false)
+	if strings.Contains(buf.String(), "par") {
+		t.Fatalf("incomplete line printed:\n%q", buf)
+	}
+
+	buf.Reset()
+	p.update(&TaskEvent{Output: []byte("tial\nend")})
+	p.render(buf, 80, 24, false)
+	s := string(stripANSI(buf.Bytes()))
+	if i, j := strings.Index(s, "partial\n"), strings.Index(s, "task"); i < 0 || j < i {
+		t.Fatalf("completed line not printed above the live area:\n%q", s)
+	}
+	if strings.Contains(s, "end") {
+		t.Fatalf("incomplete line printed:\n%q", s)
+	}
+
+	buf.Reset()
+	p.render(buf, 80, 24, true)
+	if s := string(stripANSI(buf.Bytes())); !strings.HasPrefix(strings.TrimLeft(s, "\r"), "end\n") {
+		t.Fatalf("incomplete line not flushed first by the final render:\n%q", s)
+	}
+}
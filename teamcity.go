@@ -0,0 +1,203 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// teamcityTask is the open block of a running task. It keeps what is needed
+// for its progress messages and the log dump of its error.
+type teamcityTask struct {
+	id           uint64
+	parentID     uint64
+	name         string
+	cached       bool
+	total        uint64
+	unit         *Unit
+	logs         *tail
+	lastProgress time.Time
+}
+
+// teamcityRenderer renders the task tree as TeamCity service messages. Each
+// task is a block in its own flow, so blocks of tasks running concurrently
+// are nested correctly.
+type teamcityRenderer struct {
+	tasks    map[uint64]*teamcityTask
+	finished map[uint64]bool // IDs of the tasks whose block is closed
+	logTail  int
+	interval time.Duration
+	buf      *bytes.Buffer
+}
+
+func newTeamCityRenderer(cfg *config) *teamcityRenderer {
+	return &teamcityRenderer{
+		tasks:    make(map[uint64]*teamcityTask),
+		finished: make(map[uint64]bool),
+		logTail:  cfg.logTail,
+		interval: cfg.progressInterval,
+		buf:      bytes.NewBuffer(nil),
+	}
+}
+
+func (r *teamcityRenderer) update(te *TaskEvent) {
+	if te.ID == 0 {
+		r.buf.Write(te.Output)
+		return
+	}
+
+	flowID := strconv.FormatUint(te.ID, 10)
+
+	// the block of a finished task must not be reopened, only logs written
+	// after it finished are reported
+	if r.finished[te.ID] {
+		r.logs(te.Logs, flowID)
+		return
+	}
+
+	t, ok := r.tasks[te.ID]
+	if !ok {
+		t = &teamcityTask{
+			id:       te.ID,
+			parentID: te.ParentID,
+			name:     plainName(te),
+			total:    te.Total,
+			unit:     unitOrDefault(te.Unit),
+			logs:     newTail(r.logTail),
+		}
+		r.tasks[te.ID] = t
+
+		if r.known(te.ParentID) {
+			r.message("flowStarted", "flowId", flowID, "parent", strconv.FormatUint(te.ParentID, 10))
+		} else {
+			r.message("flowStarted", "flowId", flowID)
+		}
+		r.message("blockOpened", "name", t.name, "flowId", flowID, "timestamp", timestamp(te.StartTime))
+	}
+
+	t.cached = t.cached || te.Cached
+	if te.Total > 0 {
+		t.total = te.Total
+	}
+	if te.Unit != nil {
+		t.unit = te.Unit
+	}
+	if te.LogTail != nil {
+		t.logs.setCap(*te.LogTail)
+	}
+
+	_, _ = t.logs.Write(stripANSI(te.Logs))
+	r.logs(te.Logs, flowID)
+
+	if te.Current > 0 && !te.IsDone && time.Since(t.lastProgress) >= r.interval {
+		t.lastProgress = time.Now()
+		r.progress(fmt.Sprintf("%s %s", t.name, t.unit.count(te.Current, t.total, t.total > 0, 1)))
+	}
+
+	if !te.IsDone {
+		return
+	}
+
+	switch {
+	case te.IsIncomplete:
+		r.message("message", "text", t.name+" is incomplete", "status", "WARNING", "flowId", flowID)
+	case te.IsCanceled:
+		r.message("message", "text", t.name+" was canceled", "status", "WARNING", "flowId", flowID)
+	case te.HasErr:
+		msg := failure(te)
+		attrs := []string{"text", msg, "status", "ERROR", "flowId", flowID}
+		if t.logs.Len() > 0 {
+			details := &bytes.Buffer{}
+			t.logs.writeTo(details)
+			attrs = append(attrs, "errorDetails", details.String())
+		}
+		r.message("message", attrs...)
+
+		// the error of a top-level task is the one failing the build
+		if !r.known(t.parentID) {
+			r.message("buildProblem", "description", cut(msg, 4000), "identity", identity(t.name))
+		}
+	case t.cached:
+		r.message("message", "text", t.name+" is cached", "flowId", flowID)
+	}
+
+	r.message("blockClosed", "name", t.name, "flowId", flowID, "timestamp", timestamp(te.EndTime))
+	r.message("flowFinished", "flowId", flowID)
+	delete(r.tasks, te.ID)
+	r.finished[te.ID] = true
+}
+
+// known reports whether the task with the given ID is running or finished.
+func (r *teamcityRenderer) known(id uint64) bool {
+	_, ok := r.tasks[id]
+	return ok || r.finished[id]
+}
+
+// logs writes each line of logs as a message of the flow.
+func (r *teamcityRenderer) logs(logs []byte, flowID string) {
+	if len(logs) == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(stripANSI(logs)), "\n"), "\n") {
+		r.message("message", "text", line, "flowId", flowID)
+	}
+}
+
+// message writes a service message with the given attributes, which are
+// passed as name and value pairs.
+func (r *teamcityRenderer) message(name string, attrs ...string) {
+	fmt.Fprintf(r.buf, "##teamcity[%s", name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(r.buf, " %s='%s'", attrs[i], escapeServiceMessage(attrs[i+1]))
+	}
+	fmt.Fprintln(r.buf, "]")
+}
+
+// progress writes a progressMessage, which has a single unnamed value.
+func (r *teamcityRenderer) progress(text string) {
+	fmt.Fprintf(r.buf, "##teamcity[progressMessage '%s']\n", escapeServiceMessage(text))
+}
+
+func (r *teamcityRenderer) render(w io.Writer, _, _ int, _ bool) {
+	if r.buf.Len() > 0 {
+		_, _ = w.Write(r.buf.Bytes())
+		r.buf.Reset()
+	}
+}
+
+// timestamp formats t as expected by TeamCity, the current time is used if t
+// is zero.
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.Format("2006-01-02T15:04:05.000-0700")
+}
+
+// identity returns a build problem identity for the task name, which is
+// stable across builds and does not exceed the length allowed by TeamCity.
+func identity(name string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return fmt.Sprintf("progress-%016x", h.Sum64())
+}
+
+// escapeServiceMessage escapes the value of a TeamCity service message
+// attribute.
+func escapeServiceMessage(s string) string {
+	return strings.NewReplacer(
+		"|", "||",
+		"'", "|'",
+		"\n", "|n",
+		"\r", "|r",
+		"[", "|[",
+		"]", "|]",
+		"\u0085", "|x",
+		"\u2028", "|l",
+		"\u2029", "|p",
+	).Replace(s)
+}
@@ -0,0 +1,37 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeamCityLateLogs(t *testing.T) {
+	r := newTeamCityRenderer(newConfig(nil))
+	r.update(&TaskEvent{ID: 1, Name: "a", StartTime: time.Now()})
+	r.update(&TaskEvent{ID: 1, EndTime: time.Now(), IsDone: true})
+	r.update(&TaskEvent{ID: 1, Logs: []byte("late\n")})
+
+	out := r.buf.String()
+	if n := strings.Count(out, "blockOpened"); n != 1 {
+		t.Fatalf("got %d blocks opened, want 1:\n%s", n, out)
+	}
+	if !strings.HasSuffix(out, "##teamcity[message text='late' flowId='1']\n") {
+		t.Fatalf("late log not reported as message of the flow:\n%s", out)
+	}
+}
+
+func TestEscapeServiceMessage(t *testing.T) {
+	for s, want := range map[string]string{
+		"plain":              "plain",
+		"it's":               "it|'s",
+		"a|b":                "a||b",
+		"[x]":                "|[x|]",
+		"a\r\nb":             "a|r|nb",
+		"\u0085\u2028\u2029": "|x|l|p",
+	} {
+		if got := escapeServiceMessage(s); got != want {
+			t.Errorf("escapeServiceMessage(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
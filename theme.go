@@ -0,0 +1,224 @@
+package progress
+
+import (
+	"math"
+	"strings"
+
+	"github.com/morikuni/aec"
+)
+
+// Theme defines the look of the console display. Use one of the predefined
+// themes or derive your own from them.
+type Theme struct {
+	Title  string // prefix of the title line
+	Indent string // prefix repeated for every level of nesting of a task
+	Arrow  string // prefix of every task after the indentation
+
+	BarLeft, BarRight string   // enclose the progress bar
+	BarFull, BarEmpty string   // completely filled and empty cells of the progress bar
+	BarParts          []string // partially filled cells in ascending order, e.g. eighths, optional
+
+	Done       string // symbol of successfully completed tasks
+	Failed     string // symbol of failed tasks
+	Cached     string // symbol of cached tasks
+	Canceled   string // symbol of canceled tasks
+	Incomplete string // symbol of tasks that were incomplete when the display was closed
+	Queued     string // symbol of tasks waiting to be started
+
+	Ellipsis string // marks truncated or collapsed content
+
+	Colors Palette
+}
+
+// Palette holds the styles used by a [Theme].
+type Palette struct {
+	Done      Style // completed tasks
+	Cached    Style // completed cached tasks
+	Failed    Style // failed tasks
+	Canceled  Style // canceled and incomplete tasks
+	Logs      Style // logs of running tasks and log dumps
+	ErrorText Style // error messages of failed tasks
+	Warning   Style // warnings about the task tree
+}
+
+// Style is a combination of a color and text attributes.
+type Style struct {
+	Color       Color
+	Bold, Faint bool
+}
+
+// Color is a terminal color. The zero value is the default color of the
+// terminal. Use one of the basic colors or create a 24 bit color with [RGB].
+type Color uint32
+
+// Basic terminal colors, supported by all terminals with color support.
+const (
+	ColorDefault Color = iota
+	ColorBlack
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+)
+
+var (
+	// ThemeClassic resembles the output of docker build. It is the default.
+	ThemeClassic = &Theme{
+		Title:      "+ ",
+		Indent:     "=> ",
+		Arrow:      "=> ",
+		BarLeft:    "[",
+		BarRight:   "]",
+		BarFull:    "=",
+		BarEmpty:   " ",
+		Cached:     "CACHED",
+		Canceled:   "CANCELED",
+		Incomplete: "INCOMPLETE",
+		Queued:     "QUEUED",
+		Ellipsis:   "…",
+		Colors: Palette{
+			Done:      Style{Color: ColorBlue},
+			Cached:    Style{Color: ColorBlue, Bold: true},
+			Failed:    Style{Color: ColorRed, Bold: true},
+			Canceled:  Style{Color: ColorYellow},
+			Logs:      Style{Faint: true},
+			ErrorText: Style{Color: ColorRed},
+			Warning:   Style{Color: ColorYellow},
+		},
+	}
+
+	// ThemeUnicode uses Unicode symbols and block characters with eighth-cell
+	// precision for the progress bar.
+	ThemeUnicode = &Theme{
+		Title:      "● ",
+		Indent:     "  ",
+		Arrow:      "› ",
+		BarLeft:    "▕",
+		BarRight:   "▏",
+		BarFull:    "█",
+		BarEmpty:   " ",
+		BarParts:   []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉"},
+		Done:       "✓",
+		Failed:     "✗",
+		Cached:     "⚡",
+		Canceled:   "⊘",
+		Incomplete: "◌",
+		Queued:     "…",
+		Ellipsis:   "…",
+		Colors: Palette{
+			Done:      Style{Color: RGB(0x5f, 0xd7, 0x5f)},
+			Cached:    Style{Color: RGB(0x5f, 0xaf, 0xff)},
+			Failed:    Style{Color: RGB(0xff, 0x5f, 0x5f), Bold: true},
+			Canceled:  Style{Color: RGB(0xff, 0xd7, 0x5f)},
+			Logs:      Style{Faint: true},
+			ErrorText: Style{Color: RGB(0xff, 0x5f, 0x5f)},
+			Warning:   Style{Color: RGB(0xff, 0xd7, 0x5f)},
+		},
+	}
+
+	// ThemeMonochrome looks like ThemeClassic but only uses text attributes
+	// instead of colors.
+	ThemeMonochrome = &Theme{
+		Title:      "+ ",
+		Indent:     "=> ",
+		Arrow:      "=> ",
+		BarLeft:    "[",
+		BarRight:   "]",
+		BarFull:    "=",
+		BarEmpty:   " ",
+		Failed:     "FAILED",
+		Cached:     "CACHED",
+		Canceled:   "CANCELED",
+		Incomplete: "INCOMPLETE",
+		Queued:     "QUEUED",
+		Ellipsis:   "...",
+		Colors: Palette{
+			Cached:    Style{Bold: true},
+			Failed:    Style{Bold: true},
+			Logs:      Style{Faint: true},
+			ErrorText: Style{Bold: true},
+		},
+	}
+)
+
+// ansi returns the escape sequence for the style at the given color level.
+func (s Style) ansi(level colorLevel) aec.ANSI {
+	b := aec.EmptyBuilder
+	if s.Color != ColorDefault {
+		b = b.With(s.Color.ansi(level))
+	}
+	if s.Bold {
+		b = b.Bold()
+	}
+	if s.Faint {
+		b = b.Faint()
+	}
+	return b.ANSI
+}
+
+// apply applies the style to text at the given color level.
+func (s Style) apply(text string, level colorLevel) string {
+	if s == (Style{}) || level == levelNone {
+		return text
+	}
+	return aec.Apply(text, s.ansi(level))
+}
+
+// prefix returns the prefix of a task at the given depth.
+func (th *Theme) prefix(depth int) string {
+	return strings.TrimRight(strings.Repeat(th.Indent, depth-1)+th.Arrow, " ")
+}
+
+// bar draws a progress bar of the given width including its borders.
+func (th *Theme) bar(width int, percent float64) string {
+	width -= stringWidth(th.BarLeft) + stringWidth(th.BarRight)
+	if width < 1 {
+		return ""
+	}
+
+	percent = math.Max(0, math.Min(1, percent))
+	cells := percent * float64(width)
+
+	full := int(math.Ceil(cells))
+	part := ""
+	if len(th.BarParts) > 0 {
+		full = int(cells)
+		if idx := int((cells - float64(full)) * float64(len(th.BarParts)+1)); idx > 0 && full < width {
+			part = th.BarParts[idx-1]
+		}
+	}
+
+	empty := width - full
+	if part != "" {
+		empty--
+	}
+
+	return th.BarLeft + strings.Repeat(th.BarFull, full) + part + strings.Repeat(th.BarEmpty, empty) + th.BarRight
+}
+
+// bounce draws an indeterminate progress bar of the given width with a block
+// bouncing back and forth, advancing one cell per tick.
+func (th *Theme) bounce(width, tick int) string {
+	width -= stringWidth(th.BarLeft) + stringWidth(th.BarRight)
+	if width < 1 {
+		return ""
+	}
+
+	block := width / 5
+	if block < 1 {
+		block = 1
+	}
+
+	pos := 0
+	if span := width - block; span > 0 {
+		pos = tick % (2 * span)
+		if pos > span {
+			pos = 2*span - pos
+		}
+	}
+
+	return th.BarLeft + strings.Repeat(th.BarEmpty, pos) + strings.Repeat(th.BarFull, block) + strings.Repeat(th.BarEmpty, width-pos-block) + th.BarRight
+}
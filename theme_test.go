@@ -0,0 +1,57 @@
+package progress
+
+import "testing"
+
+func TestBounce(t *testing.T) {
+	th := &Theme{BarLeft: "[", BarRight: "]", BarFull: "#", BarEmpty: " "}
+	for _, tt := range []struct {
+		width, tick int
+		want        string
+	}{
+		{12, 0, "[##        ]"},
+		{12, 1, "[ ##       ]"},
+		{12, 8, "[        ##]"},
+		{12, 9, "[       ## ]"},
+		{12, 16, "[##        ]"},
+		{3, 5, "[#]"},
+		{2, 0, ""},
+	} {
+		got := th.bounce(tt.width, tt.tick)
+		if got != tt.want {
+			t.Errorf("bounce(%d, %d) = %q, want %q", tt.width, tt.tick, got, tt.want)
+		}
+		if got != "" && stringWidth(got) != tt.width {
+			t.Errorf("bounce(%d, %d) is %d cells wide", tt.width, tt.tick, stringWidth(got))
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	for _, tt := range []struct {
+		theme   *Theme
+		percent float64
+		want    string
+	}{
+		{ThemeUnicode, 0, "▕          ▏"},
+		{ThemeUnicode, 0.5, "▕█████     ▏"},
+		{ThemeUnicode, 0.5625, "▕█████▋    ▏"},
+		{ThemeUnicode, 0.03125, "▕▎         ▏"},
+		{ThemeUnicode, 0.9990, "▕█████████▉▏"},
+		{ThemeUnicode, 1, "▕██████████▏"},
+		{ThemeUnicode, 1.5, "▕██████████▏"},
+		{ThemeClassic, 0.5625, "[======    ]"},
+		{ThemeClassic, -1, "[          ]"},
+	} {
+		got := tt.theme.bar(12, tt.percent)
+		if got != tt.want {
+			t.Errorf("bar(12, %g) = %q, want %q", tt.percent, got, tt.want)
+		}
+		if stringWidth(got) != 12 {
+			t.Errorf("bar(12, %g) is %d cells wide", tt.percent, stringWidth(got))
+		}
+	}
+
+	if got := ThemeUnicode.bar(2, 0.5); got != "" {
+		t.Errorf("bar without room = %q, want none", got)
+	}
+}
@@ -5,14 +5,15 @@ import (
 	"fmt"
 	"io"
 	"time"
-
-	"github.com/tonistiigi/units"
 )
 
 type knownTask struct {
 	started time.Time
+	parent  uint64
 	name    string
 	cached  bool
+	total   uint64
+	unit    *Unit
 }
 
 type traceRenderer struct {
@@ -20,55 +21,90 @@ type traceRenderer struct {
 	startTime time.Time
 
 	knownTasks map[uint64]*knownTask
+	duplicates duplicateStarts
 
 	buf *bytes.Buffer
 }
 
 func (t *traceRenderer) update(te *TaskEvent) {
 	if te.ID == 0 {
+		t.buf.Write(te.Output)
 		return
 	}
 
 	secs := fmt.Sprintf("%.1f", time.Since(t.startTime).Seconds())
 	header := fmt.Sprintf("[%5s]", secs)
 
+	// the trace is plain text, escape sequences must not leak into it
+	name := string(stripANSI([]byte(te.Name)))
+
 	if task, ok := t.knownTasks[te.ID]; !ok {
 		t.knownTasks[te.ID] = &knownTask{
 			started: te.StartTime,
-			name:    te.Name,
+			parent:  te.ParentID,
+			name:    name,
 			cached:  te.Cached,
+			total:   te.Total,
+			unit:    unitOrDefault(te.Unit),
 		}
 
-		fmt.Fprintf(t.buf, "%s START %q\n", header, te.Name)
+		if te.IsQueued {
+			fmt.Fprintf(t.buf, "%s QUEUED %q\n", header, name)
+		} else {
+			fmt.Fprintf(t.buf, "%s START %q\n", header, name)
+		}
+	} else if dup, first := t.duplicates.check(task.started, task.parent, te); dup {
+		if first {
+			fmt.Fprintf(t.buf, "%s WARN ignoring %q, task ID %d is already used by %q\n", header, name, te.ID, task.name)
+		}
 	} else {
+		if task.started.IsZero() && !te.StartTime.IsZero() {
+			task.started = te.StartTime
+			fmt.Fprintf(t.buf, "%s START %q\n", header, task.name)
+		}
+
 		task.cached = task.cached || te.Cached
+		if te.Total > 0 {
+			task.total = te.Total
+		}
+		if te.Unit != nil {
+			task.unit = te.Unit
+		}
 
 		if len(te.Logs) > 0 {
-			logs, _ := bytes.CutSuffix(te.Logs, []byte("\n"))
+			logs, _ := bytes.CutSuffix(stripANSI(te.Logs), []byte("\n"))
 			for _, line := range bytes.Split(logs, []byte("\n")) {
 				fmt.Fprintf(t.buf, "%s %s: %s\n", header, t.name, string(line))
 			}
 		}
 
 		if te.IsDone {
-			secsDone := fmt.Sprintf("%.1f", time.Since(task.started).Seconds())
+			endTime := te.EndTime
+			if endTime.IsZero() {
+				endTime = time.Now()
+			}
+			secsDone := fmt.Sprintf("%.1f", endTime.Sub(task.started).Seconds())
 
 			var copied string
 			if te.Current != 0 {
-				copied = fmt.Sprintf("%.2f", units.Bytes(te.Current))
-				if te.Total != 0 {
-					copied = fmt.Sprintf("%s / %.2f", copied, units.Bytes(te.Total))
-				}
-				copied = fmt.Sprintf("(%s) ", copied)
+				copied = fmt.Sprintf("(%s) ", task.unit.count(te.Current, task.total, te.Total != 0, 2))
 			}
 
 			var errStr string
-			if te.HasErr {
-				errStr = fmt.Sprintf(" with ERR %s", te.Err)
+			if te.HasErr && !te.IsCanceled {
+				msg := "unknown error"
+				if te.Err != nil {
+					msg = string(stripANSI([]byte(te.Err.Error())))
+				}
+				errStr = " with ERR " + msg
 			}
 
 			status := "DONE"
-			if task.cached {
+			if te.IsIncomplete {
+				status = "INCOMPLETE"
+			} else if te.IsCanceled {
+				status = "CANCELED"
+			} else if task.cached {
 				status = "CACHED"
 			}
 
@@ -77,7 +113,7 @@ func (t *traceRenderer) update(te *TaskEvent) {
 	}
 }
 
-func (t *traceRenderer) render(w io.Writer, _ int, _ bool) {
+func (t *traceRenderer) render(w io.Writer, _, _ int, _ bool) {
 	if t.buf.Len() > 0 {
 		_, _ = w.Write(t.buf.Bytes())
 		t.buf.Reset()
@@ -89,6 +125,7 @@ func newTraceRenderer(name string) *traceRenderer {
 		name:       name,
 		startTime:  time.Now(),
 		knownTasks: make(map[uint64]*knownTask),
+		duplicates: make(duplicateStarts),
 		buf:        bytes.NewBuffer(nil),
 	}
 }
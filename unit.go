@@ -0,0 +1,97 @@
+package progress
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tonistiigi/units"
+)
+
+// Unit determines how the progress counters of a task are formatted. Use one
+// of the predefined units or create a custom one with [NewUnit].
+type Unit struct {
+	name    string
+	format  func(v float64, prec int) string
+	percent bool
+}
+
+var (
+	// UnitBytes formats counters as bytes with decimal prefixes, e.g. "1.5MB".
+	// It is the default.
+	UnitBytes = &Unit{
+		name: "bytes",
+		format: func(v float64, prec int) string {
+			return fmt.Sprintf("%.*f", prec, units.Bytes(v))
+		},
+	}
+
+	// UnitItems formats counters as plain numbers, e.g. "340 / 1200".
+	UnitItems = &Unit{
+		name: "items",
+		format: func(v float64, prec int) string {
+			if v == math.Trunc(v) {
+				prec = 0
+			}
+			return fmt.Sprintf("%.*f", prec, v)
+		},
+	}
+
+	// UnitPercent formats Current as percentage of Total, e.g. "45.0%". If
+	// Total is 0, Current is taken as percentage.
+	UnitPercent = &Unit{
+		name: "percent",
+		format: func(v float64, prec int) string {
+			return fmt.Sprintf("%.*f%%", prec, v)
+		},
+		percent: true,
+	}
+)
+
+// NewUnit creates a custom unit. The name identifies the unit in
+// machine-readable output, format is used to format the counters and the rate.
+func NewUnit(name string, format func(v float64) string) *Unit {
+	return &Unit{
+		name: name,
+		format: func(v float64, _ int) string {
+			return format(v)
+		},
+	}
+}
+
+// Name returns the name of the unit.
+func (u *Unit) Name() string {
+	return u.name
+}
+
+// count formats the current progress and if withTotal is set the total.
+func (u *Unit) count(current, total uint64, withTotal bool, prec int) string {
+	if u.percent {
+		return u.format(u.scale(float64(current), total), prec)
+	}
+
+	s := u.format(float64(current), prec)
+	if withTotal && total > 0 {
+		s = fmt.Sprintf("%s / %s", s, u.format(float64(total), prec))
+	}
+	return s
+}
+
+// rate formats the progress per second.
+func (u *Unit) rate(perSec float64, total uint64, prec int) string {
+	return u.format(u.scale(perSec, total), prec) + "/s"
+}
+
+func (u *Unit) scale(v float64, total uint64) float64 {
+	if u.percent && total > 0 {
+		return v / float64(total) * 100
+	}
+	return v
+}
+
+// unitOrDefault returns u or UnitBytes if u is nil.
+func unitOrDefault(u *Unit) *Unit {
+	if u == nil {
+		return UnitBytes
+	}
+	return u
+}
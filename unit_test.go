@@ -0,0 +1,57 @@
+package progress
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUnitCount(t *testing.T) {
+	custom := NewUnit("layers", func(v float64) string { return fmt.Sprintf("%.0f layers", v) })
+
+	for _, tt := range []struct {
+		unit           *Unit
+		current, total uint64
+		withTotal      bool
+		want           string
+	}{
+		{UnitBytes, 1536, 0, false, "1.54kB"},
+		{UnitBytes, 1536, 3072, true, "1.54kB / 3.07kB"},
+		{UnitItems, 340, 1200, true, "340 / 1200"},
+		{UnitItems, 340, 1200, false, "340"},
+		{UnitItems, 340, 0, true, "340"},
+		{UnitPercent, 450, 1000, true, "45.00%"},
+		{UnitPercent, 45, 0, true, "45.00%"},
+		{custom, 3, 5, true, "3 layers / 5 layers"},
+	} {
+		if got := tt.unit.count(tt.current, tt.total, tt.withTotal, 2); got != tt.want {
+			t.Errorf("%s: count(%d, %d, %v) = %q, want %q", tt.unit.Name(), tt.current, tt.total, tt.withTotal, got, tt.want)
+		}
+	}
+}
+
+func TestUnitRate(t *testing.T) {
+	for _, tt := range []struct {
+		unit   *Unit
+		perSec float64
+		total  uint64
+		want   string
+	}{
+		{UnitBytes, 2048, 0, "2.0kB/s"},
+		{UnitItems, 12, 100, "12/s"},
+		{UnitItems, 1.5, 100, "1.5/s"},
+		{UnitPercent, 5, 200, "2.5%/s"},
+	} {
+		if got := tt.unit.rate(tt.perSec, tt.total, 1); got != tt.want {
+			t.Errorf("%s: rate(%g, %d) = %q, want %q", tt.unit.Name(), tt.perSec, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestUnitOrDefault(t *testing.T) {
+	if u := unitOrDefault(nil); u != UnitBytes {
+		t.Errorf("got unit %s, want bytes", u.Name())
+	}
+	if u := unitOrDefault(UnitItems); u != UnitItems {
+		t.Errorf("got unit %s, want items", u.Name())
+	}
+}
@@ -2,54 +2,82 @@ package progress
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"io"
-	"strings"
+	"time"
 
 	"github.com/tonistiigi/vt100"
 )
 
-func renderTerm(term *vt100.VT100, w io.Writer) {
+// termLines returns the used lines of term cut to width w. Lines holding
+// wide characters may be wider than the terminal emulator assumes.
+func termLines(term *vt100.VT100, w int) []string {
 	h := term.UsedHeight()
-	buf := &bytes.Buffer{}
+	lines := make([]string, 0, h)
 	for _, line := range term.Content[:h] {
-		for _, cell := range line {
-			buf.WriteRune(cell)
-		}
-		buf.WriteRune('\n')
+		lines = append(lines, cut(string(line), w))
 	}
-	_, _ = w.Write(buf.Bytes()) // explicitly ignore any errors
+	return lines
 }
 
-func align(l, r string, w int) string {
-	return fmt.Sprintf("%-[2]*[1]s %[3]s", l, w-len(r)-1, r)
-}
+// duplicateStarts records the start times of tasks started with the ID of an
+// already started task, by ID. Events repeating the start time of a recorded
+// duplicate belong to it and are dropped. Events without a start time cannot
+// be attributed and are applied to the first task with the ID.
+type duplicateStarts map[uint64][]time.Time
 
-func mkarrow(len int) string {
-	return strings.TrimSuffix(strings.Repeat("=> ", len), " ")
+// check reports whether te belongs to a task started with the ID of the task
+// started at started with the given parent, and whether te starts it. Events
+// repeating the start time and parent of the task, as sent by producers
+// sending the full state on every event, are not duplicates.
+func (d duplicateStarts) check(started time.Time, parentID uint64, te *TaskEvent) (dup, first bool) {
+	if started.IsZero() || te.StartTime.IsZero() {
+		return false, false
+	}
+	for _, s := range d[te.ID] {
+		if te.StartTime.Equal(s) {
+			return true, false
+		}
+	}
+	if te.StartTime.Equal(started) && (te.ParentID == 0 || te.ParentID == parentID) {
+		return false, false
+	}
+	d[te.ID] = append(d[te.ID], te.StartTime)
+	return true, true
 }
 
-func mkbar(width int, percent float64) string {
-	if width < 3 {
-		return ""
+// count returns the number of duplicates recorded.
+func (d duplicateStarts) count() int {
+	n := 0
+	for _, starts := range d {
+		n += len(starts)
 	}
+	return n
+}
 
-	width -= 2
-
-	sb := &strings.Builder{}
-	sb.Grow(width + 2)
+// plainName returns the name of te without terminal escape sequences, which
+// the logs of CI systems do not interpret.
+func plainName(te *TaskEvent) string {
+	return string(stripANSI([]byte(te.Name)))
+}
 
-	sb.WriteRune('[')
-	for i := 0; i < width; i++ {
-		if float64(i) < percent*float64(width) {
-			sb.WriteRune('=')
-		} else {
-			sb.WriteRune(' ')
-		}
+// failure returns the error message of the failed task te for CI systems.
+func failure(te *TaskEvent) string {
+	if te.Err == nil {
+		return "failed"
 	}
-	sb.WriteRune(']')
+	return string(stripANSI([]byte(te.Err.Error())))
+}
 
-	return sb.String()
+// align returns l and r aligned to the left and right of a line of width w.
+// If the line is too long, l is cut off.
+func align(l, r string, w int) string {
+	rw := stringWidth(r)
+	if rw >= w {
+		return cut(r, w)
+	}
+	space := w - rw - 1
+	return pad(cut(l, space), space) + " " + r
 }
 
 func merge(bufs [][]byte) []byte {
@@ -61,12 +89,17 @@ func merge(bufs [][]byte) []byte {
 }
 
 type countReader struct {
+	ctx    context.Context
 	notify func(int64)
 	r      io.Reader
 	n      int64
 }
 
 func (r *countReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	n, err := r.r.Read(p)
 	r.n += int64(n)
 	r.notify(r.n)
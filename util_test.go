@@ -0,0 +1,54 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuplicateStarts(t *testing.T) {
+	st := time.Now()
+	other := st.Add(time.Second)
+	d := make(duplicateStarts)
+
+	// producers sending the full state repeat the start time
+	if dup, _ := d.check(st, 0, &TaskEvent{ID: 1, StartTime: st, IsDone: true}); dup {
+		t.Fatal("repeated start time reported as duplicate")
+	}
+	if dup, _ := d.check(st, 0, &TaskEvent{ID: 1, Current: 10}); dup {
+		t.Fatal("update reported as duplicate")
+	}
+
+	dup, first := d.check(st, 0, &TaskEvent{ID: 1, StartTime: other})
+	if !dup || !first {
+		t.Fatalf("got dup=%v first=%v for a second start, want true, true", dup, first)
+	}
+	dup, first = d.check(st, 0, &TaskEvent{ID: 1, StartTime: other, IsDone: true})
+	if !dup || first {
+		t.Fatalf("got dup=%v first=%v for an event of the duplicate, want true, false", dup, first)
+	}
+
+	dup, _ = d.check(st, 0, &TaskEvent{ID: 1, ParentID: 5, StartTime: st})
+	if !dup {
+		t.Fatal("start with a different parent not reported as duplicate")
+	}
+
+	if n := d.count(); n != 2 {
+		t.Fatalf("got %d duplicates, want 2", n)
+	}
+}
+
+func TestTraceFullStateEvents(t *testing.T) {
+	st := time.Now()
+	r := newTraceRenderer("test")
+	r.update(&TaskEvent{ID: 1, Name: "a", StartTime: st})
+	r.update(&TaskEvent{ID: 1, Name: "a", StartTime: st, EndTime: st.Add(time.Second), IsDone: true})
+
+	out := r.buf.String()
+	if want := `DONE "a" in 1.0s`; !strings.Contains(out, want) {
+		t.Fatalf("trace %q does not contain %q", out, want)
+	}
+	if strings.Contains(out, "WARN") {
+		t.Fatalf("trace %q warns about a duplicate", out)
+	}
+}
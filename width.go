@@ -0,0 +1,146 @@
+package progress
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Truncation selects how task names that do not fit on a line are shortened.
+type Truncation int
+
+const (
+	// TruncateEnd cuts off the end of the name, e.g. "build the ima…".
+	TruncateEnd Truncation = iota
+
+	// TruncateMiddle cuts out the middle of the name, which keeps the file
+	// name of paths visible, e.g. "/usr/lo…/bin/go".
+	TruncateMiddle
+)
+
+// wideRanges are the ranges of East Asian wide and fullwidth characters and
+// emoji presented as wide, which occupy two cells in a terminal.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F202}, {0x1F210, 0x1F23B},
+	{0x1F240, 0x1F248}, {0x1F250, 0x1F251}, {0x1F260, 0x1F265}, {0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB}, {0x1F900, 0x1F9FF}, {0x1FA70, 0x1FAFF},
+	{0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of cells r occupies in a terminal.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf),
+		r >= 0xFE00 && r <= 0xFE0F,   // variation selectors
+		r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return 0
+	}
+
+	i := sort.Search(len(wideRanges), func(i int) bool { return wideRanges[i][1] >= r })
+	if i < len(wideRanges) && wideRanges[i][0] <= r {
+		return 2
+	}
+	return 1
+}
+
+// splitCells splits s into its characters with their widths. Characters joined to
+// the previous one by a zero width joiner do not occupy additional cells.
+func splitCells(s string) ([]string, []int) {
+	var chars []string
+	var widths []int
+	joined := false
+	for _, r := range s {
+		w := runeWidth(r)
+		if joined || (w == 0 && len(chars) > 0) {
+			chars[len(chars)-1] += string(r)
+			joined = r == '‍'
+			continue
+		}
+		joined = r == '‍'
+		chars = append(chars, string(r))
+		widths = append(widths, w)
+	}
+	return chars, widths
+}
+
+// stringWidth returns the number of cells s occupies in a terminal.
+func stringWidth(s string) int {
+	_, widths := splitCells(s)
+	n := 0
+	for _, w := range widths {
+		n += w
+	}
+	return n
+}
+
+// pad appends spaces to s until it occupies w cells.
+func pad(s string, w int) string {
+	if n := stringWidth(s); n < w {
+		return s + strings.Repeat(" ", w-n)
+	}
+	return s
+}
+
+// cut cuts s to at most w cells.
+func cut(s string, w int) string {
+	if stringWidth(s) <= w {
+		return s
+	}
+	chars, widths := splitCells(s)
+	sb := &strings.Builder{}
+	for i, c := range chars {
+		if w < widths[i] {
+			break
+		}
+		w -= widths[i]
+		sb.WriteString(c)
+	}
+	return sb.String()
+}
+
+// cutLeft cuts s to its last w cells at most.
+func cutLeft(s string, w int) string {
+	chars, widths := splitCells(s)
+	i := len(chars)
+	for i > 0 && widths[i-1] <= w {
+		i--
+		w -= widths[i]
+	}
+	return strings.Join(chars[i:], "")
+}
+
+// shorten shortens s to at most w cells as selected by mode. The removed
+// part is replaced with ellipsis.
+func shorten(s string, w int, ellipsis string, mode Truncation) string {
+	if stringWidth(s) <= w {
+		return s
+	}
+
+	ew := stringWidth(ellipsis)
+	if w <= ew {
+		return cut(ellipsis, w)
+	}
+
+	if mode == TruncateMiddle {
+		keep := w - ew
+		return cut(s, keep-keep/2) + ellipsis + cutLeft(s, keep/2)
+	}
+	return cut(s, w-ew) + ellipsis
+}
@@ -0,0 +1,46 @@
+package progress
+
+import "testing"
+
+func TestStringWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"日本", 4},
+		{"é", 1},
+		{"a\tb", 2},
+	} {
+		if got := stringWidth(tt.s); got != tt.want {
+			t.Errorf("stringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		w    int
+		mode Truncation
+		want string
+	}{
+		{"abcdef", 6, TruncateEnd, "abcdef"},
+		{"abcdef", 4, TruncateEnd, "abc…"},
+		{"abcdef", 4, TruncateMiddle, "ab…f"},
+		{"abcdefg", 5, TruncateMiddle, "ab…fg"},
+		{"abcdef", 1, TruncateEnd, "…"},
+		{"abcdef", 0, TruncateMiddle, ""},
+		{"日本語", 4, TruncateEnd, "日…"},
+		{"日本語", 5, TruncateMiddle, "日…語"},
+	} {
+		got := shorten(tt.s, tt.w, "…", tt.mode)
+		if got != tt.want {
+			t.Errorf("shorten(%q, %d, %v) = %q, want %q", tt.s, tt.w, tt.mode, got, tt.want)
+		}
+		if stringWidth(got) > tt.w {
+			t.Errorf("shorten(%q, %d, %v) is %d cells wide", tt.s, tt.w, tt.mode, stringWidth(got))
+		}
+	}
+}